@@ -12,6 +12,9 @@ import (
 
 const APUIONum = 4
 
+// ClockRate is the rate in Hz of the APU oscillator, it is the same for NTSC and PAL consoles
+const ClockRate = 24576000
+
 const (
 	stateInit = iota
 	stateTransfer
@@ -26,6 +29,12 @@ type APU struct {
 	cmd           uint8
 	transferIndex uint8
 
+	// masterClock is the master clock rate of the console in Hz, the APU clock is driven by the master cycles
+	// of the CPU so the remainder of the conversion is kept not to drift
+	masterClock uint64
+	remainder   uint64
+	cycles      uint64
+
 	IO0       uint8
 	IO1       uint8
 	IO2       uint8
@@ -46,6 +55,28 @@ func New(rf *io.RegisterFactory) *APU {
 	return apu
 }
 
+// SetMasterClock sets the master clock rate of the console in Hz
+// The APU has its own oscillator so the ratio between both clocks depends on the region of the console
+func (apu *APU) SetMasterClock(rate uint32) {
+	apu.masterClock = uint64(rate)
+	apu.remainder = 0
+}
+
+// Step advances the APU clock by the given number of master cycles of the console
+func (apu *APU) Step(masterCycles uint64) {
+	if apu.masterClock == 0 {
+		return
+	}
+	total := masterCycles*ClockRate + apu.remainder
+	apu.cycles += total / apu.masterClock
+	apu.remainder = total % apu.masterClock
+}
+
+// Cycles returns the number of APU oscillator cycles elapsed since the APU was created
+func (apu *APU) Cycles() uint64 {
+	return apu.cycles
+}
+
 func (apu *APU) reset() {
 	apu.isReset = true
 	apu.transferIndex = 0x00
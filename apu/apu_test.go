@@ -0,0 +1,36 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/snes-emu/gose/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockRatio(t *testing.T) {
+	apu := New(io.NewRegisterFactory())
+
+	// Nothing is counted until the master clock is known
+	apu.Step(1000)
+	assert.EqualValues(t, 0, apu.Cycles())
+
+	// One second of an NTSC console
+	apu.SetMasterClock(21477272)
+	apu.Step(21477272)
+	assert.EqualValues(t, ClockRate, apu.Cycles())
+
+	// The remainder of the conversion is kept between the steps
+	apu = New(io.NewRegisterFactory())
+	apu.SetMasterClock(21477272)
+	for i := 0; i < 21477272/6; i++ {
+		apu.Step(6)
+	}
+	apu.Step(21477272 % 6)
+	assert.EqualValues(t, ClockRate, apu.Cycles())
+
+	// A line of a PAL console
+	apu = New(io.NewRegisterFactory())
+	apu.SetMasterClock(21281370)
+	apu.Step(1364)
+	assert.EqualValues(t, 1364*ClockRate/21281370, apu.Cycles())
+}
@@ -3,15 +3,33 @@ package config
 import "flag"
 
 var (
-	debugServer bool
-	debugLogs   bool
-	debugPort   int
+	debugServer    bool
+	debugLogs      bool
+	debugPort      int
+	hiresBlend     bool
+	noWeave        bool
+	accurateAccess bool
+	region         string
+	dotRenderer    bool
+	trace          string
+	traceFormat    string
+	traceRange     string
+	traceFrames    string
 )
 
 func init() {
 	flag.BoolVar(&debugServer, "debug-server", false, "enable the debug server")
 	flag.BoolVar(&debugLogs, "debug-logs", false, "enable debug logs")
 	flag.IntVar(&debugPort, "debug-port", 6060, "port the debugger listens to")
+	flag.BoolVar(&hiresBlend, "hires-blend", false, "blend hi-res frames down to 256 pixels wide")
+	flag.BoolVar(&noWeave, "no-weave", false, "line double interlaced fields instead of weaving them together")
+	flag.BoolVar(&dotRenderer, "dot-renderer", false, "render the PPU output one dot at a time (slower but mid-line register writes take effect at the right pixel, the tile fetches are not delayed like on the console)")
+	flag.StringVar(&region, "region", "auto", "console region: auto (from the ROM header), ntsc or pal")
+	flag.StringVar(&trace, "trace", "", "write a trace of the executed instructions to this file, compressed with gzip if the name ends with .gz")
+	flag.StringVar(&traceFormat, "trace-format", "gose", "layout of the trace lines: gose, bsnes or mesen")
+	flag.StringVar(&traceRange, "trace-range", "", "only trace the instructions in this address range, for instance 00:8000-00:FFFF")
+	flag.StringVar(&traceFrames, "trace-frames", "", "only trace the instructions of this range of frames, for instance 100-200 (the end is optional)")
+	flag.BoolVar(&accurateAccess, "accurate-access", false, "drop VRAM, OAM and CGRAM writes during active display like the hardware does")
 }
 
 // Inits the config
@@ -33,3 +51,48 @@ func DebugServer() bool {
 func DebugPort() int {
 	return debugPort
 }
+
+// HiresBlend is used to know whether hi-res frames should be blended down to 256 pixels wide
+func HiresBlend() bool {
+	return hiresBlend
+}
+
+// InterlaceWeave is used to know whether interlaced fields should be woven together into full height frames
+func InterlaceWeave() bool {
+	return !noWeave
+}
+
+// AccurateAccess is used to know whether VRAM, OAM and CGRAM writes should be dropped during active display
+func AccurateAccess() bool {
+	return accurateAccess
+}
+
+// Region is used to know which console region to emulate ("auto", "ntsc" or "pal")
+func Region() string {
+	return region
+}
+
+// DotRenderer is used to know whether the PPU should render its output one dot at a time instead of one line at a time
+func DotRenderer() bool {
+	return dotRenderer
+}
+
+// Trace is the file the trace of the executed instructions is written to, empty if tracing is disabled
+func Trace() string {
+	return trace
+}
+
+// TraceFormat is the layout of the trace lines ("gose", "bsnes" or "mesen")
+func TraceFormat() string {
+	return traceFormat
+}
+
+// TraceRange is the address range of the traced instructions, empty to trace all of them
+func TraceRange() string {
+	return traceRange
+}
+
+// TraceFrames is the range of frames during which the instructions are traced, empty to trace all of them
+func TraceFrames() string {
+	return traceFrames
+}
@@ -14,7 +14,7 @@ func (cpu *CPU) admAbsoluteJ() uint16 {
 //ABSOLUTE addressing mode
 func (cpu *CPU) admAbsolute() (uint8, uint8) {
 	laddress, haddress := cpu.admAbsoluteP()
-	return cpu.memory.GetByte(laddress), cpu.memory.GetByte(haddress)
+	return cpu.readData(laddress, haddress)
 }
 
 //ABSOLUTE addressing mode pointer
@@ -27,32 +27,38 @@ func (cpu *CPU) admAbsoluteP() (uint32, uint32) {
 
 // ABSOLUTE,X addressing mode
 func (cpu *CPU) admAbsoluteX() (uint8, uint8) {
-	laddress, haddress := cpu.admAbsoluteXP()
-	return cpu.memory.GetByte(laddress), cpu.memory.GetByte(haddress)
+	laddress, haddress := cpu.admAbsoluteIndexedP(cpu.getXRegister(), false)
+	return cpu.readData(laddress, haddress)
 }
 
 // ABSOLUTE,X addressing mode pointer
 func (cpu *CPU) admAbsoluteXP() (uint32, uint32) {
-	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-	HH := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+2)
-	address := bit.JoinUint32(LL, HH, cpu.getDBRRegister())
-	cpu.pFlag = uint16(LL)+0x00FF+cpu.getXRegister()+1 > 0xFF
-	return address + uint32(cpu.getXRegister()), address + uint32(cpu.getXRegister()) + 1
+	return cpu.admAbsoluteIndexedP(cpu.getXRegister(), true)
 }
 
 // ABSOLUTE,X addressing mode
 func (cpu *CPU) admAbsoluteY() (uint8, uint8) {
-	laddr, haddr := cpu.admAbsoluteYP()
-	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	laddr, haddr := cpu.admAbsoluteIndexedP(cpu.getYRegister(), false)
+	return cpu.readData(laddr, haddr)
 }
 
 // ABSOLUTE,X addressing mode pointer
 func (cpu *CPU) admAbsoluteYP() (uint32, uint32) {
+	return cpu.admAbsoluteIndexedP(cpu.getYRegister(), true)
+}
+
+// admAbsoluteIndexedP returns the pointer of the ABSOLUTE,X and ABSOLUTE,Y addressing modes
+// Adding the index takes an extra cycle with 16-bit index registers or when a page is crossed, and always for the writes:
+// the address is on the bus before the carry of the index is added to its high byte
+func (cpu *CPU) admAbsoluteIndexedP(index uint16, write bool) (uint32, uint32) {
 	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
 	HH := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+2)
 	address := bit.JoinUint32(LL, HH, cpu.getDBRRegister())
-	cpu.pFlag = uint16(LL)+0x00FF+cpu.getYRegister()+1 > 0xFF
-	return address + uint32(cpu.getYRegister()), address + uint32(cpu.getYRegister()) + 1
+	cpu.pFlag = uint16(LL)+index > 0xFF
+	if write || !cpu.xFlag || cpu.pFlag {
+		cpu.idle(cpu.getDBRRegister(), bit.JoinUint16(LL+uint8(index), HH))
+	}
+	return address + uint32(index), address + uint32(index) + 1
 }
 
 // (ABSOLUTE) addressing mode
@@ -75,6 +81,7 @@ func (cpu *CPU) admBAbsoluteJ() (uint8, uint16) {
 func (cpu *CPU) admPAbsoluteXJ() uint16 {
 	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
 	HH := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+2)
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister()+2)
 	address := bit.JoinUint16(LL, HH) + cpu.getXRegister()
 	return bit.JoinUint16(cpu.memory.GetByteBank(cpu.getKRegister(), address), cpu.memory.GetByteBank(cpu.getKRegister(), address+1))
 }
@@ -88,28 +95,72 @@ func (cpu *CPU) admAccumulator() (uint8, uint8) {
 func (cpu *CPU) admDirect() (uint8, uint8) {
 	laddress, haddress := cpu.admDirectP()
 
-	return cpu.memory.GetByte(laddress), cpu.memory.GetByte(haddress)
+	return cpu.readData(laddress, haddress)
 }
 
 // DIRECT addressing mode pointer
 func (cpu *CPU) admDirectP() (uint32, uint32) {
+	LL := cpu.directOperand()
+	return cpu.directAddress(LL), cpu.directAddress(LL + 1)
+}
+
+// directOperand fetches the offset of the direct addressing modes,
+// adding a low byte of the direct register other than $00 takes an extra cycle
+func (cpu *CPU) directOperand() uint16 {
 	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
+	if cpu.getDLRegister() != 0x00 {
+		cpu.idleAfterOpcode()
+	}
+	return uint16(LL)
+}
+
+// directIndexedOperand fetches the offset of the direct indexed addressing modes, adding the index takes an extra cycle
+func (cpu *CPU) directIndexedOperand() uint16 {
+	LL := cpu.directOperand()
+	cpu.idleAfterOpcode()
+	return LL
+}
 
+// directAddress returns the address of the byte at the given offset in the direct page,
+// in emulation mode with DL = $00 the offset wraps inside the page like on the 6502
+func (cpu *CPU) directAddress(offset uint16) uint32 {
 	if cpu.eFlag && cpu.getDLRegister() == 0x00 {
-		address := bit.JoinUint32(LL, cpu.getDHRegister(), 0x00)
-		return address, 0x000000
+		return bit.JoinUint32(uint8(offset), cpu.getDHRegister(), 0x00)
 	}
+	return uint32(cpu.getDRegister() + offset)
+}
 
-	ll := uint16(LL)
-	laddress := uint32(cpu.getDRegister() + ll)
-	haddress := uint32(cpu.getDRegister() + ll + 1)
-	return laddress, haddress
+// readData reads the data of the accumulator and memory instructions, the high byte is only read with a 16-bit accumulator
+func (cpu *CPU) readData(laddr, haddr uint32) (uint8, uint8) {
+	if cpu.mFlag {
+		return cpu.memory.GetByte(laddr), 0x00
+	}
+	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+}
+
+// readIndex reads the data of the index register instructions, the high byte is only read with 16-bit index registers
+func (cpu *CPU) readIndex(laddr, haddr uint32) (uint8, uint8) {
+	if cpu.xFlag {
+		return cpu.memory.GetByte(laddr), 0x00
+	}
+	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+}
+
+// readModifyData reads the data of a read-modify-write instruction,
+// the data is modified during an internal operation while the address of the last byte read is still on the bus
+func (cpu *CPU) readModifyData(laddr, haddr uint32) (uint8, uint8) {
+	dataLo, dataHi := cpu.readData(laddr, haddr)
+	if cpu.mFlag {
+		cpu.idle(uint8(laddr>>16), uint16(laddr))
+	} else {
+		cpu.idle(uint8(haddr>>16), uint16(haddr))
+	}
+	return dataLo, dataHi
 }
 
-// DIRECT addressing mode for "new" intructions (only use by PEI)
+// DIRECT addressing mode for "new" intructions (only use by PEI), it never wraps inside the direct page
 func (cpu *CPU) admDirectNew() (uint8, uint8) {
-	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-	ll := uint16(LL)
+	ll := cpu.directOperand()
 	laddress := uint32(cpu.getDRegister() + ll)
 	haddress := uint32(cpu.getDRegister() + ll + 1)
 	return cpu.memory.GetByte(laddress), cpu.memory.GetByte(haddress)
@@ -118,70 +169,38 @@ func (cpu *CPU) admDirectNew() (uint8, uint8) {
 // DIRECT,X addressing mode otherwise
 func (cpu *CPU) admDirectX() (uint8, uint8) {
 	laddress, haddress := cpu.admDirectXP()
-	return cpu.memory.GetByte(laddress), cpu.memory.GetByte(haddress)
+	return cpu.readData(laddress, haddress)
 }
 
 // DIRECT,X addressing mode pointer
 func (cpu *CPU) admDirectXP() (uint32, uint32) {
-	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-
-	if cpu.eFlag && cpu.getDLRegister() == 0x00 {
-		address := bit.JoinUint32(LL+cpu.getXLRegister(), cpu.getDHRegister(), 0x00)
-		return address, 0x00
-	}
-
-	ll := uint16(LL)
-	laddress := uint32(cpu.getDRegister() + ll + cpu.getXRegister())
-	haddress := uint32(cpu.getDRegister() + ll + cpu.getXRegister() + 1)
-	return laddress, haddress
+	LL := cpu.directIndexedOperand()
+	return cpu.directAddress(LL + cpu.getXRegister()), cpu.directAddress(LL + cpu.getXRegister() + 1)
 }
 
-// DIRECT,X addressing mode otherwise pointer
+// DIRECT,Y addressing mode otherwise
 func (cpu *CPU) admDirectY() (uint8, uint8) {
 	laddress, haddress := cpu.admDirectYP()
-	return cpu.memory.GetByte(laddress), cpu.memory.GetByte(haddress)
+	return cpu.readData(laddress, haddress)
 }
 
-// DIRECT,X addressing mode otherwise
+// DIRECT,Y addressing mode pointer
 func (cpu *CPU) admDirectYP() (uint32, uint32) {
-	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-
-	if cpu.eFlag && cpu.getDLRegister() == 0x00 {
-		LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-		address := bit.JoinUint32(LL+cpu.getYLRegister(), cpu.getDHRegister(), 0x00)
-		return address, 0x00
-	}
-
-	ll := uint16(LL)
-	laddress := uint32(cpu.getDRegister() + ll + cpu.getYRegister())
-	haddress := uint32(cpu.getDRegister() + ll + cpu.getYRegister() + 1)
-	return laddress, haddress
-}
-
-// (DIRECT) addressing mode when e is 1 and DL is $00
-func (cpu *CPU) admPDirect8() (uint8, uint8) {
-	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-	laddress := bit.JoinUint32(LL, cpu.getDHRegister(), 0x00)
-	haddress := bit.JoinUint32(LL+1, cpu.getDHRegister(), 0x00)
-	ll := cpu.memory.GetByte(laddress)
-	hh := cpu.memory.GetByte(haddress)
-	pointer := bit.JoinUint32(ll, hh, cpu.getDBRRegister())
-	return cpu.memory.GetByte(pointer), cpu.memory.GetByte(pointer + 1)
+	LL := cpu.directIndexedOperand()
+	return cpu.directAddress(LL + cpu.getYRegister()), cpu.directAddress(LL + cpu.getYRegister() + 1)
 }
 
 // (DIRECT) addressing mode otherwise
 func (cpu *CPU) admPDirect() (uint8, uint8) {
 	laddress, haddress := cpu.admPDirectP()
-	return cpu.memory.GetByte(laddress), cpu.memory.GetByte(haddress)
+	return cpu.readData(laddress, haddress)
 }
 
 // (DIRECT) addressing mode pointer
 func (cpu *CPU) admPDirectP() (uint32, uint32) {
-	LL := uint16(cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1))
-	laddress := uint32(cpu.getDRegister() + LL)
-	haddress := uint32(cpu.getDRegister() + LL + 1)
-	ll := cpu.memory.GetByte(laddress)
-	hh := cpu.memory.GetByte(haddress)
+	LL := cpu.directOperand()
+	ll := cpu.memory.GetByte(cpu.directAddress(LL))
+	hh := cpu.memory.GetByte(cpu.directAddress(LL + 1))
 	pointer := bit.JoinUint32(ll, hh, cpu.getDBRRegister())
 	return pointer, pointer + 1
 }
@@ -189,13 +208,12 @@ func (cpu *CPU) admPDirectP() (uint32, uint32) {
 // [DIRECT] addressing mode
 func (cpu *CPU) admBDirect() (uint8, uint8) {
 	laddr, haddr := cpu.admBDirectP()
-	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	return cpu.readData(laddr, haddr)
 }
 
 // [DIRECT] addressing mode pointer
 func (cpu *CPU) admBDirectP() (uint32, uint32) {
-	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-	address := cpu.getDRegister() + uint16(LL)
+	address := cpu.getDRegister() + cpu.directOperand()
 	ll := cpu.memory.GetByte(uint32(address))
 	mm := cpu.memory.GetByte(uint32(address + 1))
 	hh := cpu.memory.GetByte(uint32(address + 2))
@@ -206,59 +224,40 @@ func (cpu *CPU) admBDirectP() (uint32, uint32) {
 // (DIRECT,X) addressing mode otherwise
 func (cpu *CPU) admPDirectX() (uint8, uint8) {
 	laddr, haddr := cpu.admPDirectXP()
-	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	return cpu.readData(laddr, haddr)
 
 }
 
 // (DIRECT,X) addressing mode pointer
 func (cpu *CPU) admPDirectXP() (uint32, uint32) {
-	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-
-	if cpu.eFlag && cpu.getDLRegister() == 0x00 {
-		laddress := bit.JoinUint32(LL+cpu.getXLRegister(), cpu.getDHRegister(), 0x00)
-		haddress := bit.JoinUint32(LL+cpu.getXLRegister()+1, cpu.getDHRegister(), 0x00)
-		ll := cpu.memory.GetByte(laddress)
-		hh := cpu.memory.GetByte(haddress)
-		pointer := bit.JoinUint32(ll, hh, cpu.getDBRRegister())
-		return pointer, pointer + 1
-	}
-
-	l := uint16(LL)
-	laddress := uint32(cpu.getDRegister() + l + cpu.getXRegister())
-	hadress := uint32(cpu.getDRegister() + l + cpu.getXRegister() + 1)
-	hh := cpu.memory.GetByte(hadress)
-	ll := cpu.memory.GetByte(laddress)
+	LL := cpu.directIndexedOperand()
+	ll := cpu.memory.GetByte(cpu.directAddress(LL + cpu.getXRegister()))
+	hh := cpu.memory.GetByte(cpu.directAddress(LL + cpu.getXRegister() + 1))
 	pointer := bit.JoinUint32(ll, hh, cpu.getDBRRegister())
 	return pointer, pointer + 1
-
 }
 
 // (DIRECT),Y addressing mode otherwise
 func (cpu *CPU) admPDirectY() (uint8, uint8) {
-	laddr, haddr := cpu.admPDirectYP()
-	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	laddr, haddr := cpu.admPDirectIndexedP(false)
+	return cpu.readData(laddr, haddr)
 }
 
 // (DIRECT),Y addressing mode pointer
 func (cpu *CPU) admPDirectYP() (uint32, uint32) {
-	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-
-	if cpu.eFlag && cpu.getDLRegister() == 0x00 {
-		LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-		laddress := bit.JoinUint32(LL, cpu.getDHRegister(), 0x00)
-		haddress := bit.JoinUint32(LL+1, cpu.getDHRegister(), 0x00)
-		ll := cpu.memory.GetByte(laddress)
-		hh := cpu.memory.GetByte(haddress)
-		pointer := bit.JoinUint32(ll, hh, cpu.getDBRRegister()) + uint32(cpu.getYRegister())
-		return pointer, pointer + 1
+	return cpu.admPDirectIndexedP(true)
+}
+
+// admPDirectIndexedP returns the pointer of the (DIRECT),Y addressing mode
+// Adding Y takes an extra cycle like for the absolute indexed addressing modes (see admAbsoluteIndexedP)
+func (cpu *CPU) admPDirectIndexedP(write bool) (uint32, uint32) {
+	LL := cpu.directOperand()
+	ll := cpu.memory.GetByte(cpu.directAddress(LL))
+	hh := cpu.memory.GetByte(cpu.directAddress(LL + 1))
+	cpu.pFlag = uint16(ll)+cpu.getYRegister() > 0xFF
+	if write || !cpu.xFlag || cpu.pFlag {
+		cpu.idle(cpu.getDBRRegister(), bit.JoinUint16(ll+cpu.getYLRegister(), hh))
 	}
-
-	l := uint16(LL)
-	cpu.pFlag = cpu.getDRegister()&0x00FF+l+1 > 0xFF
-	laddress := uint32(cpu.getDRegister() + l)
-	hadress := uint32(cpu.getDRegister() + l + 1)
-	hh := cpu.memory.GetByte(hadress)
-	ll := cpu.memory.GetByte(laddress)
 	pointer := bit.JoinUint32(ll, hh, cpu.getDBRRegister()) + uint32(cpu.getYRegister())
 	return pointer, pointer + 1
 }
@@ -266,13 +265,12 @@ func (cpu *CPU) admPDirectYP() (uint32, uint32) {
 // [DIRECT],Y addressing mode
 func (cpu *CPU) admBDirectY() (uint8, uint8) {
 	laddr, haddr := cpu.admBDirectYP()
-	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	return cpu.readData(laddr, haddr)
 }
 
 // [DIRECT],Y addressing mode pointer
 func (cpu *CPU) admBDirectYP() (uint32, uint32) {
-	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
-	address := cpu.getDRegister() + uint16(LL)
+	address := cpu.getDRegister() + cpu.directOperand()
 	ll := cpu.memory.GetByte(uint32(address))
 	mm := cpu.memory.GetByte(uint32(address + 1))
 	hh := cpu.memory.GetByte(uint32(address + 2))
@@ -323,7 +321,7 @@ func (cpu *CPU) admLongJ() (uint16, uint8) {
 // LONG addressing mode
 func (cpu *CPU) admLong() (uint8, uint8) {
 	laddr, haddr := cpu.admLongP()
-	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	return cpu.readData(laddr, haddr)
 }
 
 // LONG addressing mode pointer
@@ -338,7 +336,7 @@ func (cpu *CPU) admLongP() (uint32, uint32) {
 // LONG,X addressing mode
 func (cpu *CPU) admLongX() (uint8, uint8) {
 	laddr, haddr := cpu.admLongXP()
-	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	return cpu.readData(laddr, haddr)
 }
 
 // LONG,X addressing mode pointer
@@ -385,12 +383,13 @@ func (cpu *CPU) admSourceDestination() (uint8, uint16, uint8, uint16) {
 // STACK,S addressing mode
 func (cpu *CPU) admStackS() (uint8, uint8) {
 	laddress, haddress := cpu.admStackSP()
-	return cpu.memory.GetByte(laddress), cpu.memory.GetByte(haddress)
+	return cpu.readData(laddress, haddress)
 }
 
 // STACK,S addressing mode pointer
 func (cpu *CPU) admStackSP() (uint32, uint32) {
 	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
+	cpu.idleAfterOpcode()
 	laddress := uint32(cpu.getSRegister() + uint16(LL))
 	haddress := uint32(cpu.getSRegister() + uint16(LL) + 1)
 	return laddress, haddress
@@ -399,16 +398,18 @@ func (cpu *CPU) admStackSP() (uint32, uint32) {
 // (STACK,S),Y addressing mode
 func (cpu *CPU) admPStackSY() (uint8, uint8) {
 	laddr, haddr := cpu.admPStackSYP()
-	return cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	return cpu.readData(laddr, haddr)
 }
 
 // (STACK,S),Y addressing mode
 func (cpu *CPU) admPStackSYP() (uint32, uint32) {
 	LL := cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
+	cpu.idleAfterOpcode()
 	laddress := uint32(cpu.getSRegister() + uint16(LL))
 	haddress := uint32(cpu.getSRegister() + uint16(LL) + 1)
 	ll := cpu.memory.GetByte(laddress)
 	hh := cpu.memory.GetByte(haddress)
+	cpu.idle(0x00, uint16(haddress))
 	pointer := bit.JoinUint32(ll, hh, cpu.getDBRRegister()) + uint32(cpu.getYRegister())
 	return pointer, pointer + 1
 }
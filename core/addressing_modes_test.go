@@ -40,6 +40,30 @@ func TestAbsoluteX(t *testing.T) {
 	lo, hi := cpu.admAbsoluteXP()
 	assert.Equal(t, uint32(0x130008), lo)
 	assert.Equal(t, uint32(0x130009), hi)
+	assert.True(t, cpu.pFlag)
+
+	cpu.X = 0x0001
+	cpu.admAbsoluteXP()
+	assert.False(t, cpu.pFlag)
+}
+
+func TestAbsoluteY(t *testing.T) {
+	cpu := newTestCPU()
+	cpu.DBR = 0x12
+	cpu.Y = 0x000A
+
+	//opcode arguments
+	cpu.memory.SetByteBank(0xFE, 0, 1)
+	cpu.memory.SetByteBank(0xFF, 0, 2)
+
+	lo, hi := cpu.admAbsoluteYP()
+	assert.Equal(t, uint32(0x130008), lo)
+	assert.Equal(t, uint32(0x130009), hi)
+	assert.True(t, cpu.pFlag)
+
+	cpu.Y = 0x0001
+	cpu.admAbsoluteYP()
+	assert.False(t, cpu.pFlag)
 }
 
 func TestPAbsolute(t *testing.T) {
@@ -79,10 +103,17 @@ func TestDirect(t *testing.T) {
 	assert.Equal(t, uint32(0x00FFFF), lo)
 	assert.Equal(t, uint32(0x000000), hi)
 
+	// the direct page wraps in emulation mode when DL is $00
 	cpu.eFlag = true
 	cpu.mFlag = true
-	lo, _ = cpu.admDirectP()
+	lo, hi = cpu.admDirectP()
 	assert.Equal(t, uint32(0x00FFFF), lo)
+	assert.Equal(t, uint32(0x00FF00), hi)
+
+	cpu.D = 0x1E01
+	lo, hi = cpu.admDirectP()
+	assert.Equal(t, uint32(0x001F00), lo)
+	assert.Equal(t, uint32(0x001F01), hi)
 }
 
 func TestDirectX(t *testing.T) {
@@ -97,10 +128,42 @@ func TestDirectX(t *testing.T) {
 	assert.Equal(t, uint32(0x000008), lo)
 	assert.Equal(t, uint32(0x000009), hi)
 
+	// the direct page wraps in emulation mode when DL is $00
+	cpu.eFlag = true
+	cpu.mFlag = true
+	lo, hi = cpu.admDirectXP()
+	assert.Equal(t, uint32(0x00FF08), lo)
+	assert.Equal(t, uint32(0x00FF09), hi)
+
+	cpu.D = 0x1E01
+	lo, hi = cpu.admDirectXP()
+	assert.Equal(t, uint32(0x001F09), lo)
+	assert.Equal(t, uint32(0x001F0A), hi)
+}
+
+func TestDirectY(t *testing.T) {
+	cpu := newTestCPU()
+	cpu.D = 0xFF00
+	cpu.Y = 0x000A
+
+	//opcode arguments
+	cpu.memory.SetByteBank(0xFE, 0, 1)
+
+	lo, hi := cpu.admDirectYP()
+	assert.Equal(t, uint32(0x000008), lo)
+	assert.Equal(t, uint32(0x000009), hi)
+
+	// the direct page wraps in emulation mode when DL is $00
 	cpu.eFlag = true
 	cpu.mFlag = true
-	lo, _ = cpu.admDirectXP()
+	lo, hi = cpu.admDirectYP()
 	assert.Equal(t, uint32(0x00FF08), lo)
+	assert.Equal(t, uint32(0x00FF09), hi)
+
+	cpu.D = 0x1E01
+	lo, hi = cpu.admDirectYP()
+	assert.Equal(t, uint32(0x001F09), lo)
+	assert.Equal(t, uint32(0x001F0A), hi)
 }
 
 func TestPDirect(t *testing.T) {
@@ -118,10 +181,12 @@ func TestPDirect(t *testing.T) {
 	assert.Equal(t, uint32(0x12FFFF), lo)
 	assert.Equal(t, uint32(0x130000), hi)
 
+	// the high byte of the pointer is read at the start of the direct page in emulation mode when DL is $00
+	cpu.memory.SetByteBank(0x34, 0, 0x1E00)
 	cpu.eFlag = true
 	cpu.mFlag = true
 	lo, _ = cpu.admPDirectP()
-	assert.Equal(t, uint32(0x12FFFF), lo)
+	assert.Equal(t, uint32(0x1234FF), lo)
 }
 
 func TestBDirect(t *testing.T) {
@@ -137,6 +202,13 @@ func TestBDirect(t *testing.T) {
 	lo, hi := cpu.admBDirectP()
 	assert.Equal(t, uint32(0x12FFFF), lo)
 	assert.Equal(t, uint32(0x130000), hi)
+
+	// [DIRECT] never wraps inside the direct page
+	cpu.memory.SetByteBank(0xFF, 0, 0x1E00)
+	cpu.eFlag = true
+	cpu.mFlag = true
+	lo, _ = cpu.admBDirectP()
+	assert.Equal(t, uint32(0x12FFFF), lo)
 }
 
 func TestPDirectX(t *testing.T) {
@@ -156,10 +228,13 @@ func TestPDirectX(t *testing.T) {
 	assert.Equal(t, uint32(0x12FFFF), lo)
 	assert.Equal(t, uint32(0x130000), hi)
 
+	// the pointer is read at the start of the direct page in emulation mode when DL is $00
+	cpu.memory.SetByteBank(0x78, 0, 0x1E08)
+	cpu.memory.SetByteBank(0x56, 0, 0x1E09)
 	cpu.eFlag = true
 	cpu.mFlag = true
 	lo, _ = cpu.admPDirectXP()
-	assert.Equal(t, uint32(0x12FFFF), lo)
+	assert.Equal(t, uint32(0x125678), lo)
 }
 
 func TestPDirectY(t *testing.T) {
@@ -177,11 +252,20 @@ func TestPDirectY(t *testing.T) {
 	lo, hi := cpu.admPDirectYP()
 	assert.Equal(t, uint32(0x130008), lo)
 	assert.Equal(t, uint32(0x130009), hi)
+	assert.True(t, cpu.pFlag)
 
+	// the high byte of the pointer is read at the start of the direct page in emulation mode when DL is $00
+	cpu.memory.SetByteBank(0x34, 0, 0x1E00)
 	cpu.eFlag = true
 	cpu.mFlag = true
 	lo, _ = cpu.admPDirectYP()
-	assert.Equal(t, uint32(0x130008), lo)
+	assert.Equal(t, uint32(0x123508), lo)
+	assert.True(t, cpu.pFlag)
+
+	cpu.Y = 0x0001
+	lo, _ = cpu.admPDirectYP()
+	assert.Equal(t, uint32(0x1234FF), lo)
+	assert.False(t, cpu.pFlag)
 }
 
 func TestBDirectY(t *testing.T) {
@@ -198,6 +282,13 @@ func TestBDirectY(t *testing.T) {
 	lo, hi := cpu.admBDirectYP()
 	assert.Equal(t, uint32(0x130006), lo)
 	assert.Equal(t, uint32(0x130007), hi)
+
+	// [DIRECT],Y never wraps inside the direct page
+	cpu.memory.SetByteBank(0x00, 0, 0x1E00)
+	cpu.eFlag = true
+	cpu.mFlag = true
+	lo, _ = cpu.admBDirectYP()
+	assert.Equal(t, uint32(0x130006), lo)
 }
 
 func TestImmediate(t *testing.T) {
@@ -271,6 +362,48 @@ func TestStackS(t *testing.T) {
 	lo, hi := cpu.admStackSP()
 	assert.Equal(t, uint32(0x00000A), lo)
 	assert.Equal(t, uint32(0x00000B), hi)
+
+	// STACK,S is not confined to page 1 in emulation mode
+	cpu.eFlag = true
+	cpu.S = 0x01F0
+	lo, _ = cpu.admStackSP()
+	assert.Equal(t, uint32(0x0002EA), lo)
+}
+
+func TestRelative(t *testing.T) {
+	cpu := newTestCPU()
+	cpu.PC = 0x01F0
+	cpu.memory.SetByteBank(0xD0, 0, 0x01F0) // BNE $0212
+	cpu.memory.SetByteBank(0x20, 0, 0x01F1)
+	cpu.memory.SetByteBank(0xD0, 0, 0x0212) // BNE $0202
+	cpu.memory.SetByteBank(0xEE, 0, 0x0213)
+
+	assert.Equal(t, uint16(0x0020), cpu.admRelative8())
+	cpu.execOpcode()
+	assert.Equal(t, uint16(0x0212), cpu.PC)
+	// 2 reads from the low WRAM (8 master cycles each) and an internal operation (6 master cycles)
+	assert.Equal(t, uint16(22), cpu.cycles)
+
+	// a taken branch to another page costs an extra cycle in emulation mode
+	cpu.PC = 0x01F0
+	cpu.cycles = 0
+	cpu.eFlag = true
+	cpu.execOpcode()
+	assert.Equal(t, uint16(0x0212), cpu.PC)
+	assert.Equal(t, uint16(28), cpu.cycles)
+
+	cpu.cycles = 0
+	cpu.execOpcode()
+	assert.Equal(t, uint16(0x0202), cpu.PC)
+	assert.Equal(t, uint16(22), cpu.cycles)
+
+	// not taken branches never cost it
+	cpu.PC = 0x01F0
+	cpu.cycles = 0
+	cpu.zFlag = true
+	cpu.execOpcode()
+	assert.Equal(t, uint16(0x01F2), cpu.PC)
+	assert.Equal(t, uint16(16), cpu.cycles)
 }
 
 func TestStackSY(t *testing.T) {
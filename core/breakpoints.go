@@ -17,7 +17,12 @@ func (e *Emulator) SetBreakpoint(addr uint32) {
 	e.breakpoint = addr
 }
 
+// atBreakpoint returns true if the next instruction to execute is at the breakpoint address
+// The PC doesn't change while the CPU is waiting or stopped, the breakpoint is only reached once the CPU resumes
 func (e *Emulator) atBreakpoint() bool {
+	if e.CPU.waiting || e.CPU.stopped {
+		return false
+	}
 	return e.breakpoint != 0 && uint16(e.breakpoint&0xFFFF) == e.CPU.PC && uint8(e.breakpoint>>16) == e.CPU.K
 }
 
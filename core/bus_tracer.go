@@ -0,0 +1,65 @@
+package core
+
+// BusCycleType is the kind of operation performed by the CPU during a bus cycle
+type BusCycleType string
+
+const (
+	BusRead     BusCycleType = "read"
+	BusWrite    BusCycleType = "write"
+	BusInternal BusCycleType = "internal" // internal operation, the address is on the bus but nothing is read nor written
+)
+
+// BusCycle represents a memory access (or an internal operation) performed by the CPU
+type BusCycle struct {
+	Address uint32       `json:"address"`
+	Value   uint8        `json:"value"`
+	Type    BusCycleType `json:"type"`
+	Cycles  uint16       `json:"cycles"` // number of master cycles of the bus cycle
+}
+
+// busTracer records the bus cycles of the last executed instruction in the order in which they happen
+// The accesses made by the DMA are not bus cycles of the CPU and are not recorded.
+// The internal operations which are not performed explicitly by the instruction are recorded at its end (see CPU.step)
+type busTracer struct {
+	cycles    []BusCycle
+	recording bool
+}
+
+// start clears the bus cycles and starts recording those of a new instruction
+func (t *busTracer) start() {
+	t.cycles = t.cycles[:0]
+	t.recording = true
+}
+
+// record adds a bus cycle to the current instruction
+func (t *busTracer) record(K uint8, offset uint16, value uint8, typ BusCycleType, cycles uint16) {
+	if !t.recording {
+		return
+	}
+
+	t.cycles = append(t.cycles, BusCycle{
+		Address: uint32(K)<<16 | uint32(offset),
+		Value:   value,
+		Type:    typ,
+		Cycles:  cycles,
+	})
+}
+
+// end stops recording
+func (t *busTracer) end() {
+	t.recording = false
+}
+
+// EnableBusTracer starts recording the bus cycles of every executed instruction
+func (memory *Memory) EnableBusTracer() {
+	memory.tracer = &busTracer{}
+}
+
+// BusCycles returns the bus cycles of the last executed instruction (nil if the bus tracer is disabled)
+func (memory *Memory) BusCycles() []BusCycle {
+	if memory.tracer == nil {
+		return nil
+	}
+
+	return append([]BusCycle(nil), memory.tracer.cycles...)
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusTracer(t *testing.T) {
+	cpu := newTestCPU()
+	cpu.memory.SetByteBank(0xAD, 0x00, 0x0100) // LDA $1234
+	cpu.memory.SetByteBank(0x34, 0x00, 0x0101)
+	cpu.memory.SetByteBank(0x12, 0x00, 0x0102)
+	cpu.memory.SetByteBank(0xEA, 0x00, 0x0103) // NOP
+	cpu.memory.SetByteBank(0xCD, 0x00, 0x1234)
+	cpu.memory.SetByteBank(0xAB, 0x00, 0x1235)
+	cpu.PC = 0x0100
+
+	// Nothing is recorded while the tracer is disabled
+	assert.Nil(t, cpu.memory.BusCycles())
+
+	cpu.memory.EnableBusTracer()
+	cpu.execOpcode()
+	assert.Equal(t, []BusCycle{
+		{Address: 0x000100, Value: 0xAD, Type: BusRead, Cycles: 8},
+		{Address: 0x000101, Value: 0x34, Type: BusRead, Cycles: 8},
+		{Address: 0x000102, Value: 0x12, Type: BusRead, Cycles: 8},
+		{Address: 0x001234, Value: 0xCD, Type: BusRead, Cycles: 8},
+		{Address: 0x001235, Value: 0xAB, Type: BusRead, Cycles: 8},
+	}, cpu.memory.BusCycles())
+
+	// Cycles which are not memory accesses are internal operations
+	cpu.execOpcode()
+	assert.Equal(t, []BusCycle{
+		{Address: 0x000103, Value: 0xEA, Type: BusRead, Cycles: 8},
+		{Address: 0x000104, Type: BusInternal, Cycles: 6},
+	}, cpu.memory.BusCycles())
+
+	// Memory accesses outside of instructions are not recorded
+	cpu.memory.SetByteBank(0x00, 0x00, 0x1234)
+	assert.Len(t, cpu.memory.BusCycles(), 2)
+
+	// Writes are recorded with the written value, an 8-bit accumulator only reads and writes a byte
+	cpu.memory.SetByteBank(0x8D, 0x00, 0x0104) // STA $0010
+	cpu.memory.SetByteBank(0x10, 0x00, 0x0105)
+	cpu.memory.SetByteBank(0x00, 0x00, 0x0106)
+	cpu.mFlag = true
+	cpu.execOpcode()
+	cycles := cpu.memory.BusCycles()
+	assert.Len(t, cycles, 4)
+	assert.Equal(t, BusCycle{Address: 0x000010, Value: 0xCD, Type: BusWrite, Cycles: 8}, cycles[3])
+
+	// Internal operations are recorded where they happen, adding the index to a page crossing address
+	// puts the address on the bus before the carry is added to its high byte
+	cpu.memory.SetByteBank(0xBD, 0x00, 0x0107) // LDA $12F0,X
+	cpu.memory.SetByteBank(0xF0, 0x00, 0x0108)
+	cpu.memory.SetByteBank(0x12, 0x00, 0x0109)
+	cpu.memory.SetByteBank(0x5A, 0x00, 0x1310)
+	cpu.xFlag = true
+	cpu.X = 0x20
+	cpu.execOpcode()
+	assert.Equal(t, []BusCycle{
+		{Address: 0x000107, Value: 0xBD, Type: BusRead, Cycles: 8},
+		{Address: 0x000108, Value: 0xF0, Type: BusRead, Cycles: 8},
+		{Address: 0x000109, Value: 0x12, Type: BusRead, Cycles: 8},
+		{Address: 0x001210, Type: BusInternal, Cycles: 6},
+		{Address: 0x001310, Value: 0x5A, Type: BusRead, Cycles: 8},
+	}, cpu.memory.BusCycles())
+
+	// The internal operations of a read-modify-write instruction happen between the read and the write
+	cpu.memory.SetByteBank(0xE6, 0x00, 0x010A) // INC $10
+	cpu.memory.SetByteBank(0x10, 0x00, 0x010B)
+	cpu.D = 0x0001
+	cpu.execOpcode()
+	assert.Equal(t, []BusCycle{
+		{Address: 0x00010A, Value: 0xE6, Type: BusRead, Cycles: 8},
+		{Address: 0x00010B, Value: 0x10, Type: BusRead, Cycles: 8},
+		{Address: 0x00010B, Type: BusInternal, Cycles: 6},
+		{Address: 0x000011, Value: 0x00, Type: BusRead, Cycles: 8},
+		{Address: 0x000011, Type: BusInternal, Cycles: 6},
+		{Address: 0x000011, Value: 0x01, Type: BusWrite, Cycles: 8},
+	}, cpu.memory.BusCycles())
+}
+
+func TestBusTracerDMA(t *testing.T) {
+	cpu := newTestConsole(false)
+	mem := cpu.memory
+
+	// channel 0 copies 2 bytes from $00:0200 to CGDATA
+	channel := cpu.dmaChannels[0]
+	channel.srcBank, channel.srcAddr = 0x00, 0x0200
+	channel.destAddr = 0x22
+	channel.transferSize = 2
+	channel.transferMode = 0
+	channel.transferDirection = false
+
+	mem.SetByteBank(0x8D, 0x00, 0x0100) // STA $420B
+	mem.SetByteBank(0x0B, 0x00, 0x0101)
+	mem.SetByteBank(0x42, 0x00, 0x0102)
+	cpu.PC = 0x0100
+	cpu.mFlag = true
+	cpu.C = 0x01
+
+	// The transfers are not bus cycles of the CPU but the clock runs during them
+	mem.EnableBusTracer()
+	cpu.execOpcode()
+	assert.Equal(t, []BusCycle{
+		{Address: 0x000100, Value: 0x8D, Type: BusRead, Cycles: 8},
+		{Address: 0x000101, Value: 0x0B, Type: BusRead, Cycles: 8},
+		{Address: 0x000102, Value: 0x42, Type: BusRead, Cycles: 8},
+		{Address: 0x00420B, Value: 0x01, Type: BusWrite, Cycles: 6},
+	}, mem.BusCycles())
+	assert.EqualValues(t, 0, channel.transferSize)
+	assert.EqualValues(t, 8*3+6+dmaStartCycles+dmaChannelCycles+2*dmaByteCycles, cpu.cycles)
+}
@@ -80,7 +80,7 @@ func (cpu2 CPU) compare(cpu CPU) error {
 		msg += fmt.Sprintf("Page boundary crossed virtual flag value not matching, expected: %v, received: %v\n", cpu.pFlag, cpu2.pFlag)
 	}
 
-	if !reflect.DeepEqual(cpu.memory, cpu2.memory) {
+	if !sameMemory(cpu.memory, cpu2.memory) {
 		msg += fmt.Sprintf("Memories are not matching")
 	}
 
@@ -90,3 +90,14 @@ func (cpu2 CPU) compare(cpu CPU) error {
 
 	return nil
 }
+
+// sameMemory compares the contents of two memories, the last value on the data bus and the bus cycle count are ignored
+func sameMemory(memory, memory2 *Memory) bool {
+	if memory == nil || memory2 == nil {
+		return memory == memory2
+	}
+	m, m2 := *memory, *memory2
+	m.mdr, m2.mdr = 0, 0
+	m.busCycles, m2.busCycles = 0, 0
+	return reflect.DeepEqual(m, m2)
+}
@@ -2,10 +2,13 @@ package core
 
 import (
 	"archive/zip"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
 
 	"github.com/snes-emu/gose/apu"
+	"github.com/snes-emu/gose/config"
 	"github.com/snes-emu/gose/io"
 	"github.com/snes-emu/gose/log"
 	"github.com/snes-emu/gose/render"
@@ -22,6 +25,7 @@ type Emulator struct {
 	// state
 	state       *state
 	stopChan    chan struct{}
+	stopOnce    sync.Once
 	stepChan    chan int
 	notifyPause chan struct{}
 
@@ -55,17 +59,34 @@ func New(renderer render.Renderer, debug bool) *Emulator {
 	}
 
 	apu := apu.New(rf)
-	ppu := newPPU(renderer, rf)
+	ppu := newPPU(renderer, rf, config.DotRenderer())
 	mem := newMemory()
 	cpu := newCPU(mem, rf)
 
 	cpu.ppu = ppu
 	ppu.cpu = cpu
+	ppu.pacer = newPacer(ppu.frameCycles(), ppu.masterClock())
+	apu.SetMasterClock(ppu.masterClock())
 
 	mem.cpu = cpu
 	mem.ppu = ppu
 	mem.apu = apu
 	mem.initIo(rf)
+	if debug {
+		// bus cycles are exported to the debugger
+		mem.EnableBusTracer()
+	}
+	if config.Trace() != "" {
+		trace, err := openTraceLogger(config.Trace(), traceOptions{
+			format: config.TraceFormat(),
+			ranges: config.TraceRange(),
+			frames: config.TraceFrames(),
+		})
+		if err != nil {
+			log.Fatal("failed to open the trace file", zap.Error(err))
+		}
+		cpu.trace = trace
+	}
 
 	e.Memory = mem
 	e.CPU = cpu
@@ -108,24 +129,59 @@ func readFile(src string) ([]byte, error) {
 	return ioutil.ReadAll(f)
 }
 
-// ReadROM open the rom at filename and load it in memory
-func (e *Emulator) ReadROM(filename string) {
+// ReadROMFile reads and parses the rom at filename, raw ROMs and .zip files are supported
+func ReadROMFile(filename string) (*rom.ROM, error) {
 	buf, err := readFile(filename)
 	if err != nil {
-		log.Fatal("error when reading rom file", zap.Error(err))
+		return nil, fmt.Errorf("error when reading rom file: %w", err)
 	}
 
-	rom, err := rom.ParseROM(buf)
+	return rom.ParseROM(buf)
+}
+
+// ReadROM open the rom at filename and load it in memory
+func (e *Emulator) ReadROM(filename string) {
+	rom, err := ReadROMFile(filename)
 	if err != nil {
-		log.Fatal("an error occurred while parsing the ROM", zap.Error(err))
+		log.Fatal("an error occurred while reading the ROM", zap.Error(err))
 	}
 	log.Info("success parsing rom", zap.String("name", rom.Title))
 
 	e.PPU.renderer.SetRomTitle(rom.Title)
 	e.Memory.LoadROM(*rom)
+	e.setRegion(region(rom.Region))
 	e.CPU.Init()
 }
 
+// region returns whether a PAL console should be emulated for a ROM of the given region
+// The region found in the ROM header can be overridden using the region flag
+func region(romRegion uint) bool {
+	switch config.Region() {
+	case "ntsc":
+		return false
+	case "pal":
+		return true
+	case "auto":
+		return romRegion == rom.PAL
+	default:
+		log.Fatal("unknown region, possible values are auto, ntsc and pal", zap.String("region", config.Region()))
+		return false
+	}
+}
+
+// setRegion sets the region of the emulated console, PAL consoles run at 50Hz with 312 lines per frame
+// and NTSC consoles at 60Hz with 262 lines per frame
+func (e *Emulator) setRegion(pal bool) {
+	e.PPU.setRegion(pal)
+	e.Memory.apu.SetMasterClock(e.PPU.masterClock())
+
+	name := "NTSC"
+	if pal {
+		name = "PAL"
+	}
+	log.Info("console region", zap.String("region", name))
+}
+
 func (e *Emulator) loop() {
 	n := 0
 	for {
@@ -226,9 +282,16 @@ func (e *Emulator) IsPaused() bool {
 	return e.state.Status() == paused
 }
 
-// Stop stops the emulation
+// Stop stops the emulation, it can safely be called more than once
 func (e *Emulator) Stop() {
-	close(e.stopChan)
+	e.stopOnce.Do(func() { close(e.stopChan) })
+
+	// the trace is flushed now as the process usually exits right after the emulation is stopped
+	if e.CPU.trace != nil {
+		if err := e.CPU.trace.Close(); err != nil {
+			log.Error("failed to write the trace file", zap.Error(err))
+		}
+	}
 }
 
 // StepAndWait continues the execution for the given number of steps (if given 0 it will loop until a pause is triggered or the emulator is stopped)
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"github.com/snes-emu/gose/bit"
 	"github.com/snes-emu/gose/io"
 )
 
@@ -26,11 +25,18 @@ type CPU struct {
 	S       uint16 // The stack pointer register
 	X       uint16 // The X index register
 	Y       uint16 // The Y index register
-	cycles  uint16 // Number of cycles
+	cycles  uint16 // Number of master cycles elapsed since the start of the current line
+	elapsed uint64 // Number of master cycles elapsed since power on
 	waiting bool   // CPU Waiting mode (from operation wait)
-	memory  *Memory
-	ppu     *PPU
-	opcodes [256]cpuOperation
+	stopped bool   // CPU Stopped mode (from operation stop), only a reset restarts the CPU
+
+	timerCycles uint16       // Master cycle of the current line up to which the H/V timer was checked
+	nmiLine     bool         // NMI line, see updateNMILine
+	nmiPending  bool         // NMI requested by a rising edge of the NMI line, serviced before the next instruction
+	trace       *traceLogger // Writes the executed instructions to a trace file, nil if tracing is disabled
+	memory      *Memory
+	ppu         *PPU
+	opcodes     [256]cpuOperation
 	// CPU io registers
 	// 0x4000 - 0x437F with 0x4000 - 0x4015, 0x4018 - 0x41FF, 0x420E - 0x420F, 0x4220- 0X42FF and 0x43xC being unused
 	ioRegisters [0x380]*io.Register
@@ -41,22 +47,65 @@ type CPU struct {
 
 type cpuOperation func()
 
+// idleCycles is the number of CPU cycles elapsed between two checks for interrupts while the CPU is waiting or stopped
+const idleCycles = 2
+
 var opcodes []cpuOperation
 
 func newCPU(memory *Memory, rf *io.RegisterFactory) *CPU {
 	cpu := &CPU{memory: memory}
+	// the unused io registers are not driven, they read the last value of the CPU data bus
+	rf.SetOpenBus(memory.openBus)
 	cpu.initIORegisters(rf)
 	cpu.registerOpcodes()
 	return cpu
 }
 
+// step ends an instruction (or an interrupt sequence) which lasted the given number of CPU cycles
+// Its bus cycles last 6, 8 or 12 master cycles depending on the accessed address (see Memory.accessCycles),
+// the remaining CPU cycles are internal operations lasting 6 master cycles
 func (cpu *CPU) step(cycles uint16) {
+	// the instructions are also tested on CPUs without memory, all their cycles are internal operations
+	if cpu.memory == nil {
+		cpu.advance(cycles * internalCycles)
+		return
+	}
+
+	for cpu.memory.busCycles < cycles {
+		cpu.idle(cpu.getKRegister(), cpu.getPCRegister())
+	}
+	cpu.memory.busCycles = 0
+}
+
+// idle performs an internal operation of the CPU, the given address is on the bus but nothing is read nor written
+func (cpu *CPU) idle(K uint8, offset uint16) {
+	cpu.memory.busCycle(internalCycles)
+	cpu.memory.trace(K, offset, 0, BusInternal, internalCycles)
+}
+
+// idleAfterOpcode performs an internal operation while the address following the opcode is on the bus
+func (cpu *CPU) idleAfterOpcode() {
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister()+1)
+}
+
+// advance runs the clock of the console for the given number of master cycles
+func (cpu *CPU) advance(cycles uint16) {
 	cpu.cycles += cycles
+	cpu.elapsed += uint64(cycles)
+	if cpu.memory != nil && cpu.memory.apu != nil {
+		cpu.memory.apu.Step(uint64(cycles))
+	}
 
-	if cpu.cycles > 1364 {
+	// Lines are at least ShortLineCycles long, the exact length depends on the line (see PPU.lineCycles)
+	if cpu.cycles < ShortLineCycles {
+		return
+	}
+
+	if lineCycles := cpu.ppu.lineCycles(); cpu.cycles >= lineCycles {
+		cpu.updateTimerIRQ(cpu.timerCycles, lineCycles)
+		cpu.cycles -= lineCycles
+		cpu.timerCycles = 0
 		cpu.ppu.renderLine()
-		cpu.HandleIRQ()
-		cpu.cycles = 0
 	}
 }
 
@@ -65,66 +114,48 @@ func (cpu *CPU) Init() {
 	cpu.reset()
 }
 
+// pushStack pushes a byte on the stack for the instructions inherited from the 6502,
+// in emulation mode the stack pointer wraps inside page 1 after each byte
 func (cpu *CPU) pushStack(data uint8) {
-	if cpu.eFlag {
-		cpu.memory.SetByteBank(data, 0x00, bit.JoinUint16(cpu.getSLRegister(), 0x01))
-		cpu.setSLRegister(cpu.getSLRegister() - 1)
-	} else {
-		cpu.memory.SetByteBank(data, 0x00, cpu.getSRegister())
-		cpu.S--
-	}
+	cpu.pushStackNew(data)
+	cpu.confineStack()
 }
 
+// pullStack pulls a byte from the stack for the instructions inherited from the 6502,
+// in emulation mode the stack pointer wraps inside page 1 after each byte
 func (cpu *CPU) pullStack() uint8 {
-	var data uint8
-	if cpu.eFlag {
-		data = cpu.memory.GetByteBank(0x00, bit.JoinUint16(cpu.getSLRegister()+1, 0x01))
-		cpu.setSLRegister(cpu.getSLRegister() + 1)
-		return data
-	}
-	data = cpu.memory.GetByteBank(0x00, cpu.getSRegister()+1)
 	cpu.S++
-	return data
+	cpu.confineStack()
+	return cpu.memory.GetByteBank(0x00, cpu.getSRegister())
 }
 
-func (cpu *CPU) pushStackNew8(data uint8) {
-	cpu.pushStackNew(data)
-	if cpu.eFlag {
-		cpu.setSHRegister(0x01)
-	}
-}
+// The instructions added by the 65C816 (PEA, PEI, PER, PHD, PLB, PLD, JSL, RTL and JSR (a,X))
+// do not wrap inside page 1: the whole data is pushed or pulled with the 16-bit stack pointer,
+// which is only moved back to page 1 afterwards in emulation mode
 
 func (cpu *CPU) pushStackNew16(dataLo, dataHi uint8) {
 	cpu.pushStackNew(dataHi)
 	cpu.pushStackNew(dataLo)
-	if cpu.eFlag {
-		cpu.setSHRegister(0x01)
-	}
+	cpu.confineStack()
 }
 
 func (cpu *CPU) pushStackNew24(dataLo, dataMid, dataHi uint8) {
 	cpu.pushStackNew(dataHi)
 	cpu.pushStackNew(dataMid)
 	cpu.pushStackNew(dataLo)
-	if cpu.eFlag {
-		cpu.setSHRegister(0x01)
-	}
+	cpu.confineStack()
 }
 
 func (cpu *CPU) pullStackNew8() (data uint8) {
 	data = cpu.pullStackNew()
-	if cpu.eFlag {
-		cpu.setSHRegister(0x01)
-	}
+	cpu.confineStack()
 	return
 }
 
 func (cpu *CPU) pullStackNew16() (dataLo, dataHi uint8) {
 	dataLo = cpu.pullStackNew()
 	dataHi = cpu.pullStackNew()
-	if cpu.eFlag {
-		cpu.setSHRegister(0x01)
-	}
+	cpu.confineStack()
 	return
 }
 
@@ -132,20 +163,25 @@ func (cpu *CPU) pullStackNew24() (dataLo, dataMid, dataHi uint8) {
 	dataLo = cpu.pullStackNew()
 	dataMid = cpu.pullStackNew()
 	dataHi = cpu.pullStackNew()
-	if cpu.eFlag {
-		cpu.setSHRegister(0x01)
-	}
+	cpu.confineStack()
 	return
 }
 
+// pushStackNew writes a byte at the top of the stack and decrements the 16-bit stack pointer
 func (cpu *CPU) pushStackNew(data uint8) {
 	cpu.memory.SetByteBank(data, 0x00, cpu.getSRegister())
 	cpu.S--
 }
 
+// pullStackNew increments the 16-bit stack pointer and reads the byte at the top of the stack
 func (cpu *CPU) pullStackNew() uint8 {
-	var data uint8
-	data = cpu.memory.GetByteBank(0x00, cpu.getSRegister()+1)
 	cpu.S++
-	return data
+	return cpu.memory.GetByteBank(0x00, cpu.getSRegister())
+}
+
+// confineStack moves the stack pointer back to page 1 in emulation mode
+func (cpu *CPU) confineStack() {
+	if cpu.eFlag {
+		cpu.setSHRegister(0x01)
+	}
 }
@@ -70,23 +70,35 @@ func (cpu *CPU) initDma(rf *io.RegisterFactory) {
 	cpu.initUnusedx(rf)
 }
 
+// DMA timings in master cycles: the CPU is paused while the bytes are transferred
+// See: https://problemkaputt.de/fullsnes.htm#snesdmatransfers
+const (
+	dmaByteCycles    = 8  // transfer of one byte
+	dmaChannelCycles = 8  // overhead of each enabled channel
+	dmaStartCycles   = 18 // the transfer starts and ends aligned on the clocks, which takes 12 to 24 master cycles
+)
+
 func (cpu *CPU) startDma() {
 	log.Debug("dma started")
+	cpu.advance(dmaStartCycles)
 	for _, channel := range cpu.dmaChannels {
 		if !channel.dmaEnabled {
 			continue
 		}
+		cpu.advance(dmaChannelCycles)
 		transferCount := uint8(0)
 		for ok := true; ok; ok = channel.transferSize != 0 {
 			cpuBank, cpuOffset := channel.cpuAddress()
 			ppuBank, ppuOffset := channel.ppuAddress(transferCount)
+			// the transfers are not bus cycles of the CPU
 			if channel.transferDirection {
-				data := cpu.memory.GetByteBank(ppuBank, ppuOffset)
-				cpu.memory.SetByteBank(data, cpuBank, cpuOffset)
+				data := cpu.memory.getByteBank(ppuBank, ppuOffset)
+				cpu.memory.setByteBank(data, cpuBank, cpuOffset)
 			} else {
-				data := cpu.memory.GetByteBank(cpuBank, cpuOffset)
-				cpu.memory.SetByteBank(data, ppuBank, ppuOffset)
+				data := cpu.memory.getByteBank(cpuBank, cpuOffset)
+				cpu.memory.setByteBank(data, ppuBank, ppuOffset)
 			}
+			cpu.advance(dmaByteCycles)
 			transferCount++
 			channel.transferSize--
 		}
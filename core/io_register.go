@@ -13,11 +13,12 @@ type ioMemory struct {
 	vBlankNMIEnable bool         // VBlank NMI Enable  (0=Disable, 1=Enable) (Initially disabled on reset)
 	hvIRQ           uint8        // H/V IRQ (0=Disable, 1=At H=H + V=Any, 2=At V=V + H=0, 3=At H=H + V=V)
 	joypadEnable    bool         // Joypad Enable    (0=Disable, 1=Enable Automatic Reading of Joypad) TODO
+	wrio            uint8        // I/O port output, bit 7 is connected to the PPU counters latch
 	vBlankNMIFlag   bool         // (0=None, 1=Interrupt Request) (set on Begin of Vblank)
 }
 
 func (cpu *CPU) initIORegisters(rf *io.RegisterFactory) {
-	cpu.ioMemory = &ioMemory{bytes: [0x380]uint8{}}
+	cpu.ioMemory = &ioMemory{bytes: [0x380]uint8{}, wrio: 0xFF}
 	for i := 0; i < 0x380; i++ {
 		cpu.ioRegisters[i] = rf.NewRegister(nil, nil)
 	}
@@ -65,27 +66,38 @@ func (cpu *CPU) joywr(data uint8) {
 }
 
 // 0x4016/Read  - JOYA  - Joypad Input Register A (R)
+// Bits 7-2 are open bus
 func (cpu *CPU) joya() uint8 {
 	// TODO
-	return 0
+	return cpu.memory.openBus() & 0xFC
 }
 
 // 0x4017/Read  - JOYB  - Joypad Input Register B (R)
+// Bits 7-5 are open bus and bits 4-2 are always set
 func (cpu *CPU) joyb() uint8 {
 	// TODO
-	return 0
+	return cpu.memory.openBus()&0xE0 | 0x1C
 }
 
 // 0x4200 - NMITIMEN- Interrupt Enable and Joypad Request (W)
 func (cpu *CPU) nmitimen(data uint8) {
 	cpu.ioMemory.vBlankNMIEnable = data&0x80 != 0
+	cpu.updateNMILine()
 	cpu.ioMemory.hvIRQ = (data & 0x30) >> 4
+	// Disabling the H/V timer acknowledges the IRQ
+	if cpu.ioMemory.hvIRQ == 0 {
+		cpu.ioMemory.irqFlag = false
+	}
 	cpu.ioMemory.joypadEnable = data&0x01 != 0
 }
 
 // 0x4201 - WRIO    - Joypad Programmable I/O Port (Open-Collector Output) (W)
+// The H/V counters are latched when bit 7 goes from 1 to 0
 func (cpu *CPU) wrio(data uint8) {
-	// TODO
+	if cpu.ioMemory.wrio&0x80 != 0 && data&0x80 == 0 {
+		cpu.ppu.latchCounter()
+	}
+	cpu.ioMemory.wrio = data
 }
 
 // 0x4202 - WRMPYA  - Set unsigned 8bit Multiplicand (W)
@@ -144,7 +156,7 @@ func (cpu *CPU) htimel(data uint8) {
 
 // 0x4208 - H0xTIME  - H-Count Timer Setting (upper 1bit) (W)
 func (cpu *CPU) h0xtime(data uint8) {
-	cpu.ioMemory.hirqPos = (cpu.ioMemory.hirqPos & 0xff00) | ((uint16(data) << 8) & 0x100)
+	cpu.ioMemory.hirqPos = (cpu.ioMemory.hirqPos & 0x00ff) | ((uint16(data) << 8) & 0x100)
 }
 
 // 0x4209 - VTIMEL  - V-Count Timer Setting (lower 8bits) (W)
@@ -154,29 +166,34 @@ func (cpu *CPU) vtimel(data uint8) {
 
 // 0x420A - V0xTIME  - V-Count Timer Setting (upper 1bit) (W)
 func (cpu *CPU) v0xtime(data uint8) {
-	cpu.ioMemory.virqPos = (cpu.ioMemory.virqPos & 0xff00) | ((uint16(data) << 8) & 0x100)
+	cpu.ioMemory.virqPos = (cpu.ioMemory.virqPos & 0x00ff) | ((uint16(data) << 8) & 0x100)
 }
 
 // 0x420D - MEMSEL  - Memory-2 Waitstate Control (W)
+// Bit 0 selects the fast ROM access time in banks 80-FF
 func (cpu *CPU) memsel(data uint8) {
-	// TODO
+	cpu.memory.fastROM = data&1 != 0
 }
 
 // 0x4210 - RDNMI   - V-Blank NMI Flag and CPU Version Number (Read/Ack) (R)
+// Bits 6-4 are open bus
 func (cpu *CPU) rdnmi() uint8 {
 	// TODO: maybe the version is not correct there
 	version := uint8(2)
-	res := (bit.BoolToUint8(cpu.ioMemory.vBlankNMIFlag)<<7 | version)
+	res := (bit.BoolToUint8(cpu.ioMemory.vBlankNMIFlag)<<7 | cpu.memory.openBus()&0x70 | version)
+	// An NMI already requested is still serviced
 	cpu.ioMemory.vBlankNMIFlag = false
+	cpu.updateNMILine()
 	return res
 }
 
 // 0x4211 - TIMEUP  - H/V-Timer IRQ Flag (Read/Ack)  (R)
+// Bits 6-0 are open bus
 func (cpu *CPU) timeup() uint8 {
-	var res uint8
+	res := cpu.memory.openBus() & 0x7F
 
 	if cpu.ioMemory.irqFlag {
-		res = 0x80
+		res |= 0x80
 		cpu.ioMemory.irqFlag = false
 	}
 
@@ -184,14 +201,15 @@ func (cpu *CPU) timeup() uint8 {
 }
 
 // 0x4212 - HVBJOY  - H/V-Blank flag and Joypad Busy flag (R) (R)
+// Bits 5-1 are open bus
 func (cpu *CPU) hvbjoy() uint8 {
 	// TODO: bit 0 of res should be used there !!! see documentation for further information
-	var res uint8
+	res := cpu.memory.openBus() & 0x3E
 
 	// HBlank
 	hc := cpu.ppu.HCounter()
 
-	if hc < HBLANKEND || hc > HBLANKSTART {
+	if hc < HBLANKEND || hc >= HBLANKSTART {
 		res |= 0x40
 	}
 
@@ -204,9 +222,9 @@ func (cpu *CPU) hvbjoy() uint8 {
 }
 
 // 0x4213 - RDIO    - Joypad Programmable I/O Port (Input)  (R)
+// Nothing is connected to the port so the written value is read back
 func (cpu *CPU) rdio() uint8 {
-	// TODO
-	return 0
+	return cpu.ioMemory.wrio
 }
 
 // 0x4214 - RDDIVL  - Unsigned Division Result (Quotient) (lower 8bit)  (R)
@@ -1,38 +1,94 @@
 package core
 
-import (
-	"fmt"
-	"os"
-)
+// H counter values at which the HBlank flag of HVBJOY is set and cleared
+const HBLANKSTART = 274
+const HBLANKEND = 1
 
-const HBLANKSTART = 1096
-const HBLANKEND = 3
-
-func (cpu *CPU) HandleIRQ() {
+// updateTimerIRQ raises the IRQ line if the H/V timer position was reached between the given master cycles of the current line
+// The IRQ line stays up until TIMEUP is read or the timer is disabled
+func (cpu *CPU) updateTimerIRQ(from, to uint16) {
+	var hPos uint16
 	switch cpu.ioMemory.hvIRQ {
 	// 0: Disabled
 	case 0:
-		// 1: H=H and V=any
+		return
+	// 1: H=H and V=any
 	case 1:
-		cpu.irq()
-		// 2: H=0 and V=V, 3: H=H and V=V
-	case 2, 3:
-		if cpu.ppu.VCounter() == cpu.ioMemory.virqPos {
-			cpu.irq()
+		hPos = cpu.ioMemory.hirqPos
+	// 2: H=0 and V=V
+	case 2:
+		if cpu.ppu.VCounter() != cpu.ioMemory.virqPos {
+			return
+		}
+	// 3: H=H and V=V
+	case 3:
+		if cpu.ppu.VCounter() != cpu.ioMemory.virqPos {
+			return
 		}
+		hPos = cpu.ioMemory.hirqPos
+	}
 
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown value for cpu.ioMemory.hvIRQ: %v", cpu.ioMemory.hvIRQ)
+	// H positions after the last dot of the line are never reached
+	if cpu.ppu.hCounterAt(from) <= hPos && hPos < cpu.ppu.hCounterAt(to) {
+		cpu.ioMemory.irqFlag = true
 	}
 }
 
+// checkTimerIRQ checks the H/V timer up to the current master cycle of the line
+func (cpu *CPU) checkTimerIRQ() {
+	cpu.updateTimerIRQ(cpu.timerCycles, cpu.cycles)
+	cpu.timerCycles = cpu.cycles
+}
+
+// HandleIRQ services the IRQ if the IRQ line is up, this is done between two instructions
+// The IRQ wakes the CPU up from WAI even if interrupts are disabled, in this case the execution simply resumes
+// It returns true if the IRQ was serviced or woke the CPU up
+func (cpu *CPU) HandleIRQ() bool {
+	cpu.checkTimerIRQ()
+	if !cpu.ioMemory.irqFlag {
+		return false
+	}
+
+	if !cpu.iFlag {
+		cpu.waiting = false
+		cpu.irq()
+		return true
+	}
+
+	woken := cpu.waiting
+	cpu.waiting = false
+	return woken
+}
+
 func (cpu *CPU) enterVblank() {
 	cpu.ioMemory.vBlankNMIFlag = true
-	if cpu.ioMemory.vBlankNMIEnable {
-		cpu.nmi()
-	}
+	cpu.updateNMILine()
 }
 
 func (cpu *CPU) leavVblank() {
 	cpu.ioMemory.vBlankNMIFlag = false
+	cpu.updateNMILine()
+}
+
+// updateNMILine updates the NMI line which is up while the NMI flag of RDNMI is set and the NMI is enabled
+// The NMI is edge triggered: it is requested when the line goes up, which also happens if it is enabled in the middle of the VBlank
+func (cpu *CPU) updateNMILine() {
+	line := cpu.ioMemory.vBlankNMIFlag && cpu.ioMemory.vBlankNMIEnable
+	if line && !cpu.nmiLine {
+		cpu.nmiPending = true
+	}
+	cpu.nmiLine = line
+}
+
+// HandleNMI services the requested NMI, this is done between two instructions
+// It returns true if an NMI was serviced
+func (cpu *CPU) HandleNMI() bool {
+	if !cpu.nmiPending {
+		return false
+	}
+
+	cpu.nmiPending = false
+	cpu.waiting = false
+	cpu.nmi()
+	return true
 }
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runUntilIRQ advances the clock by 4 master cycles until the IRQ line is up and returns the position at which it happened
+func runUntilIRQ(cpu *CPU, maxLines int) (uint16, uint16, bool) {
+	for i := 0; i < maxLines*CyclesPerLine/4; i++ {
+		cpu.advance(4)
+		cpu.checkTimerIRQ()
+		if cpu.ioMemory.irqFlag {
+			return cpu.ppu.VCounter(), cpu.ppu.HCounter(), true
+		}
+	}
+	return 0, 0, false
+}
+
+func TestTimerIRQ(t *testing.T) {
+	// H=H and V=any
+	ppu := newTestCounterPPU()
+	cpu := ppu.cpu
+	cpu.htimel(0x20)
+	cpu.h0xtime(0x01)
+	cpu.nmitimen(0x10)
+	v, h, ok := runUntilIRQ(cpu, 2)
+	assert.True(t, ok)
+	assert.EqualValues(t, 0, v)
+	assert.EqualValues(t, 0x121, h)
+
+	// The IRQ line stays up until TIMEUP is read
+	cpu.advance(CyclesPerLine)
+	cpu.checkTimerIRQ()
+	assert.True(t, cpu.ioMemory.irqFlag)
+	assert.EqualValues(t, 0x80, cpu.timeup())
+	assert.EqualValues(t, 0x00, cpu.timeup())
+	v, h, ok = runUntilIRQ(cpu, 2)
+	assert.True(t, ok)
+	assert.EqualValues(t, 2, v)
+	assert.EqualValues(t, 0x121, h)
+
+	// H=0 and V=V
+	ppu = newTestCounterPPU()
+	cpu = ppu.cpu
+	cpu.vtimel(0x05)
+	cpu.nmitimen(0x20)
+	v, h, ok = runUntilIRQ(cpu, 10)
+	assert.True(t, ok)
+	assert.EqualValues(t, 5, v)
+	assert.EqualValues(t, 1, h)
+
+	// H=H and V=V
+	ppu = newTestCounterPPU()
+	cpu = ppu.cpu
+	cpu.htimel(0x40)
+	cpu.vtimel(0x03)
+	cpu.nmitimen(0x30)
+	v, h, ok = runUntilIRQ(cpu, 10)
+	assert.True(t, ok)
+	assert.EqualValues(t, 3, v)
+	assert.EqualValues(t, 0x41, h)
+
+	// Disabling the timer acknowledges the IRQ
+	cpu.nmitimen(0x00)
+	assert.False(t, cpu.ioMemory.irqFlag)
+	_, _, ok = runUntilIRQ(cpu, 300)
+	assert.False(t, ok)
+
+	// H positions after the end of the line never trigger an IRQ
+	ppu = newTestCounterPPU()
+	cpu = ppu.cpu
+	cpu.htimel(0x60)
+	cpu.h0xtime(0x01)
+	cpu.nmitimen(0x10)
+	_, _, ok = runUntilIRQ(cpu, 2)
+	assert.False(t, ok)
+}
+
+func TestTimerIRQInstructions(t *testing.T) {
+	ppu := newTestCounterPPU()
+	cpu := ppu.cpu
+	cpu.iFlag = true
+	for i := uint16(0); i < 0x10; i++ {
+		cpu.memory.SetByteBank(0xEA, 0x00, 0x0100+i) // NOP
+	}
+	cpu.PC = 0x0100
+
+	// H=0x20 and V=any, the dot 0x20 starts 128 master cycles after the start of the line
+	cpu.htimel(0x20)
+	cpu.h0xtime(0x00)
+	cpu.nmitimen(0x10)
+
+	// A NOP lasts 14 master cycles: 8 for the opcode fetch from the low WRAM and 6 for the internal operation
+	for i := 0; i < 9; i++ {
+		cpu.execOpcode()
+	}
+	cpu.checkTimerIRQ()
+	assert.False(t, cpu.ioMemory.irqFlag)
+	assert.EqualValues(t, 126/4, ppu.HCounter())
+
+	cpu.execOpcode()
+	cpu.checkTimerIRQ()
+	assert.True(t, cpu.ioMemory.irqFlag)
+	assert.EqualValues(t, 140/4, ppu.HCounter())
+}
+
+func TestHandleIRQ(t *testing.T) {
+	ppu := newTestCounterPPU()
+	cpu := ppu.cpu
+	cpu.PC = 0x1234
+
+	// Nothing happens while the IRQ line is down
+	cpu.HandleIRQ()
+	assert.EqualValues(t, 0x1234, cpu.PC)
+
+	// The IRQ is not serviced when interrupts are disabled but the CPU is woken up
+	cpu.ioMemory.irqFlag = true
+	cpu.iFlag = true
+	cpu.waiting = true
+	cpu.HandleIRQ()
+	assert.EqualValues(t, 0x1234, cpu.PC)
+	assert.False(t, cpu.waiting)
+
+	cpu.iFlag = false
+	cpu.S = 0x1FF
+	cpu.HandleIRQ()
+	assert.NotEqual(t, uint16(0x1234), cpu.PC)
+	assert.True(t, cpu.iFlag)
+	assert.EqualValues(t, 0x1FF-4, cpu.S)
+}
+
+func TestNMI(t *testing.T) {
+	ppu := newTestCounterPPU()
+	cpu := ppu.cpu
+	cpu.S = 0x1FF
+
+	// The NMI is requested at the start of the VBlank and serviced before the next instruction
+	cpu.nmitimen(0x80)
+	cpu.enterVblank()
+	assert.True(t, cpu.nmiPending)
+	cpu.HandleNMI()
+	assert.False(t, cpu.nmiPending)
+	assert.True(t, cpu.iFlag)
+	assert.EqualValues(t, 0x1FF-4, cpu.S)
+
+	// Writing NMITIMEN again while the line is up doesn't request another NMI
+	cpu.nmitimen(0x80)
+	assert.False(t, cpu.nmiPending)
+
+	// Enabling the NMI during the VBlank requests an NMI if the flag is still set
+	cpu.nmitimen(0x00)
+	cpu.nmitimen(0x80)
+	assert.True(t, cpu.nmiPending)
+
+	// Reading RDNMI acknowledges the flag, the requested NMI is still serviced
+	assert.EqualValues(t, 0x82, cpu.rdnmi())
+	assert.EqualValues(t, 0x02, cpu.rdnmi())
+	assert.True(t, cpu.nmiPending)
+	cpu.HandleNMI()
+	cpu.nmitimen(0x00)
+	cpu.nmitimen(0x80)
+	assert.False(t, cpu.nmiPending)
+
+	// The flag is cleared at the end of the VBlank
+	cpu.nmitimen(0x00)
+	cpu.enterVblank()
+	cpu.leavVblank()
+	cpu.nmitimen(0x80)
+	assert.False(t, cpu.nmiPending)
+	assert.EqualValues(t, 0x02, cpu.rdnmi())
+}
+
+func TestWaitForInterrupt(t *testing.T) {
+	ppu := newTestCounterPPU()
+	cpu := ppu.cpu
+	cpu.S = 0x1FF
+	cpu.memory.SetByteBank(0xCB, 0x00, 0x0100) // WAI
+	cpu.memory.SetByteBank(0xEA, 0x00, 0x0101) // NOP
+	cpu.PC = 0x0100
+
+	// No instruction is executed while waiting but the PPU keeps running
+	cpu.execOpcode()
+	assert.True(t, cpu.waiting)
+	for i := 0; i <= CyclesPerLine/(idleCycles*internalCycles); i++ {
+		cpu.execOpcode()
+	}
+	assert.EqualValues(t, 0x0101, cpu.PC)
+	assert.EqualValues(t, 1, ppu.VCounter())
+
+	// An IRQ resumes the execution even when interrupts are disabled
+	cpu.iFlag = true
+	cpu.ioMemory.irqFlag = true
+	cpu.execOpcode()
+	assert.False(t, cpu.waiting)
+	assert.EqualValues(t, 0x0101, cpu.PC)
+	cpu.execOpcode()
+	assert.EqualValues(t, 0x0102, cpu.PC)
+
+	// An NMI is serviced
+	cpu.ioMemory.irqFlag = false
+	cpu.PC = 0x0100
+	cpu.execOpcode()
+	assert.True(t, cpu.waiting)
+	cpu.nmiPending = true
+	cpu.execOpcode()
+	assert.False(t, cpu.waiting)
+	assert.EqualValues(t, 0x1FF-4, cpu.S)
+}
+
+func TestStop(t *testing.T) {
+	ppu := newTestCounterPPU()
+	cpu := ppu.cpu
+	cpu.memory.SetByteBank(0xDB, 0x00, 0x0100) // STP
+	cpu.PC = 0x0100
+
+	// Interrupts don't restart the CPU, only a reset does
+	cpu.execOpcode()
+	assert.True(t, cpu.stopped)
+	cpu.nmiPending = true
+	cpu.ioMemory.irqFlag = true
+	for i := 0; i <= CyclesPerLine/(idleCycles*internalCycles); i++ {
+		cpu.execOpcode()
+	}
+	assert.True(t, cpu.stopped)
+	assert.EqualValues(t, 0x0101, cpu.PC)
+	assert.EqualValues(t, 1, ppu.VCounter())
+
+	cpu.reset()
+	assert.False(t, cpu.stopped)
+}
@@ -7,7 +7,7 @@ import (
 )
 
 const regionNumber = 0x1000
-const bankNumber = 0x100
+const flatSize = 0x1000000
 const offsetMask = 0xFFFF
 const wramSize = 0x20000
 const ioSize = 0x8000
@@ -20,12 +20,14 @@ const (
 	romRegion
 	wramRegion
 	sramRegion
+	flatRegion // plain read/write memory, used to run the CPU against a flat 24-bit address space
 )
 
 // Memory struct containing SNES working RAM, cartridge static RAM, special hardware registers and default memory buffer for ROM
 type Memory struct {
 	mmap    [regionNumber]memoryRegion
-	main    [bankNumber][]uint8
+	rom     *rom.ROM // cartridge ROM, its bytes are decoded from the addresses by rom.Offset
+	flat    []uint8  // content of the flat regions, nil unless they are used
 	sram    []uint8
 	wram    [wramSize]uint8
 	io      [ioSize]*io.Register
@@ -34,14 +36,17 @@ type Memory struct {
 	apu     *apu.APU
 	ppu     *PPU
 	cpu     *CPU
+	tracer  *busTracer // records the bus cycles of the CPU, nil unless enabled
+	mdr     uint8      // memory data register: last value on the CPU data bus, read back from the unmapped addresses
+	fastROM bool       // set by MEMSEL: the ROM in banks 80-FF is accessed in 6 master cycles instead of 8
+
+	clock     *CPU   // CPU executing an instruction, its clock runs during the bus cycles (nil outside of the instructions)
+	busCycles uint16 // number of bus cycles of the current instruction, see CPU.step
 }
 
 // New creates a Memory struct and initialize it
 func newMemory() *Memory {
 	memory := &Memory{}
-	for bank := 0; bank < bankNumber; bank++ {
-		memory.main[bank] = make([]byte, 0x10000)
-	}
 
 	//set rom region as default
 	for region := 0; region < regionNumber; region++ {
@@ -50,38 +55,12 @@ func newMemory() *Memory {
 	return memory
 }
 
-// LoadROM takes a memory buffer and load it into memory depending ROM type
+// LoadROM maps a ROM into memory depending on its type
+// The ROM is mapped according to: https://en.wikibooks.org/wiki/Super_NES_Programming/SNES_memory_map
 func (memory *Memory) LoadROM(r rom.ROM) {
-	romSize := len(r.Data)
-
-	// set the ROM section according to: https://en.wikibooks.org/wiki/Super_NES_Programming/SNES_memory_map
-	switch r.Type {
-	case rom.LoROM:
-		memory.romType = rom.LoROM
-		for bank := 0x00; bank < 0x80; bank++ {
-			for offset := 0x8000; offset < 0x10000; offset++ {
-				if pos := offset + (bank-1)*0x8000; pos < romSize {
-					memory.main[bank][offset] = r.Data[pos]
-				}
-			}
-		}
-	case rom.HiROM:
-		memory.romType = rom.HiROM
-		for bank := 0x00; bank < 0x40; bank++ {
-			for offset := 0; offset < 0x10000; offset++ {
-				if pos := offset + bank*0x10000; pos < romSize {
-					memory.main[bank][offset] = r.Data[pos]
-					memory.main[bank+0x40][offset] = r.Data[pos]
-				}
-			}
-		}
-	}
+	memory.rom = &r
+	memory.romType = r.Type
 	memory.sram = make([]uint8, r.SRAMSize)
-
-	//upper banks are mirrors of the lower ones
-	for bank := 0x80; bank < 0x100; bank++ {
-		memory.main[bank] = memory.main[bank-0x80]
-	}
 	memory.initMmap()
 }
 
@@ -152,19 +131,92 @@ func (memory *Memory) GetByte(index uint32) uint8 {
 
 //GetByteBank gets a byte by memory bank and offset
 func (memory *Memory) GetByteBank(K uint8, offset uint16) uint8 {
+	cycles := memory.accessCycles(K, offset)
+	memory.busCycle(cycles)
+	value := memory.getByteBank(K, offset)
+	memory.trace(K, offset, value, BusRead, cycles)
+	memory.mdr = value
+	return value
+}
+
+// accessCycles returns the number of master cycles of a CPU access to the given address
+// See: https://problemkaputt.de/fullsnes.htm#snesmemorymap
+func (memory *Memory) accessCycles(K uint8, offset uint16) uint16 {
+	switch {
+	// ROM area: banks 40-7F and 80-FF, and upper halves of the other banks
+	case K&0x40 != 0 || offset&0x8000 != 0:
+		if K&0x80 != 0 && memory.fastROM {
+			return 6
+		}
+		return 8
+	// 0000-1FFF and 6000-7FFF: low WRAM and expansion
+	case (offset+0x6000)&0x4000 != 0:
+		return 8
+	// 2000-3FFF and 4200-5FFF: PPU, APU and CPU registers
+	case (offset-0x4000)&0x7E00 != 0:
+		return 6
+	// 4000-41FF: joypad registers
+	default:
+		return 12
+	}
+}
+
+// internalCycles is the number of master cycles of an internal operation of the CPU
+const internalCycles = 6
+
+// busCycle accounts for a bus cycle of the CPU which lasts the given number of master cycles
+// The clock runs before the access is done so the PPU sees it at the position it happens (H/V counter latches, mid-line writes)
+func (memory *Memory) busCycle(cycles uint16) {
+	memory.busCycles++
+	if memory.clock != nil {
+		memory.clock.advance(cycles)
+	}
+}
+
+// trace records a bus cycle of the CPU if the bus tracer is enabled
+func (memory *Memory) trace(K uint8, offset uint16, value uint8, typ BusCycleType, cycles uint16) {
+	if memory.tracer != nil {
+		memory.tracer.record(K, offset, value, typ, cycles)
+	}
+}
+
+// openBus returns the value read when nothing drives the data bus: the last value which was on it
+func (memory *Memory) openBus() uint8 {
+	return memory.mdr
+}
+
+// Peek gets a byte by its complete address without any side effect: io registers are not read and the access is not traced
+func (memory *Memory) Peek(index uint32) uint8 {
+	K, offset := uint8(index>>16), uint16(index)
+	if memory.mmap[uint16(K)<<4|offset>>12] == ioRegisterRegion {
+		return 0
+	}
+	return memory.getByteBank(K, offset)
+}
+
+func (memory *Memory) getByteBank(K uint8, offset uint16) uint8 {
 	switch memory.mmap[uint16(K)<<4|offset>>12] {
 	case lowWramRegion:
 		return memory.wram[offset]
 	case ioRegisterRegion:
 		return memory.io[offset].Read()
 	case romRegion:
-		return memory.main[K][offset]
+		// the addresses which are not decoded to the ROM are open bus
+		if memory.rom == nil {
+			return memory.mdr
+		}
+		if pos, ok := memory.rom.Offset(uint32(K)<<16 | uint32(offset)); ok {
+			return memory.rom.Data[pos]
+		}
+		return memory.mdr
 	case wramRegion:
 		return memory.wram[(uint32(K%0x80)-0x7E)<<16|uint32(offset)]
 	case sramRegion:
 		return memory.sram[memory.sm.getAddr(K, offset)%uint32(len(memory.sram))]
+	case flatRegion:
+		return memory.flat[uint32(K)<<16|uint32(offset)]
 	default:
-		return 0x00
+		return memory.mdr
 	}
 }
 
@@ -177,6 +229,16 @@ func (memory *Memory) SetByte(value uint8, index uint32) {
 
 //SetByteBank sets a byte by memory bank and offset
 func (memory *Memory) SetByteBank(value uint8, K uint8, offset uint16) {
+	cycles := memory.accessCycles(K, offset)
+	memory.busCycle(cycles)
+	memory.trace(K, offset, value, BusWrite, cycles)
+	memory.setByteBank(value, K, offset)
+}
+
+// setByteBank writes a byte without accounting for a CPU bus cycle
+func (memory *Memory) setByteBank(value uint8, K uint8, offset uint16) {
+	memory.mdr = value
+
 	switch memory.mmap[uint16(K)<<4|offset>>12] {
 	case lowWramRegion:
 		memory.wram[offset] = value
@@ -186,6 +248,8 @@ func (memory *Memory) SetByteBank(value uint8, K uint8, offset uint16) {
 		memory.wram[(uint32(K%0x80)-0x7E)<<16+uint32(offset)] = value
 	case sramRegion:
 		memory.sram[memory.sm.getAddr(K, offset)%uint32(len(memory.sram))] = value
+	case flatRegion:
+		memory.flat[uint32(K)<<16|uint32(offset)] = value
 	}
 }
 
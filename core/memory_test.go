@@ -3,6 +3,9 @@ package core
 import (
 	"testing"
 
+	"github.com/snes-emu/gose/apu"
+	"github.com/snes-emu/gose/io"
+	"github.com/snes-emu/gose/render"
 	"github.com/snes-emu/gose/rom"
 
 	"github.com/stretchr/testify/assert"
@@ -81,8 +84,7 @@ func TestWramGetSet(t *testing.T) {
 
 func TestROMGetSet(t *testing.T) {
 	mem := newMemory()
-	mem.romType = rom.LoROM
-	mem.initMmap()
+	mem.LoadROM(rom.ROM{Data: make([]byte, 0x100000), Type: rom.LoROM})
 
 	value := uint8(0xDE)
 	bank := uint8(0x20)
@@ -135,3 +137,127 @@ func TestRomMappingHirom(t *testing.T) {
 
 	assert.Equal(t, value, mem.GetByteBank(0x40, offset))
 }
+
+func TestRomMirroring(t *testing.T) {
+	// every 64KB block of the ROM starts with its index
+	newROM := func(size int, romType uint) rom.ROM {
+		data := make([]byte, size)
+		for pos := 0; pos < size; pos += 0x10000 {
+			data[pos] = uint8(pos >> 16)
+		}
+		return rom.ROM{Data: data, Type: romType}
+	}
+
+	testCases := []struct {
+		name    string
+		size    int
+		romType uint
+		mirrors map[uint32]uint8 // address -> 64KB block read there
+	}{
+		{"4Mbit HiROM", 0x80000, rom.HiROM, map[uint32]uint8{0xC00000: 0, 0xC70000: 7, 0xC80000: 0, 0x7D0000: 5}},
+		{"12Mbit HiROM", 0x180000, rom.HiROM, map[uint32]uint8{0xCF0000: 0x0F, 0xD00000: 0x10, 0xD80000: 0x10, 0xDF0000: 0x17, 0xE00000: 0x00}},
+		{"20Mbit HiROM", 0x280000, rom.HiROM, map[uint32]uint8{0xE70000: 0x27, 0xE80000: 0x20, 0xF00000: 0x20, 0xFF0000: 0x27}},
+		{"24Mbit HiROM", 0x300000, rom.HiROM, map[uint32]uint8{0xEF0000: 0x2F, 0xF00000: 0x20, 0xFF0000: 0x2F}},
+		{"12Mbit LoROM", 0x180000, rom.LoROM, map[uint32]uint8{0x1E8000: 0x0F, 0x208000: 0x10, 0x308000: 0x10, 0x3E8000: 0x17, 0x408000: 0x00}},
+		// the lower halves of banks $40-$6F mirror the upper ones
+		{"8Mbit LoROM", 0x100000, rom.LoROM, map[uint32]uint8{0x420000: 1, 0xE20000: 1, 0x400000: 0}},
+	}
+
+	for _, tc := range testCases {
+		mem := newMemory()
+		mem.LoadROM(newROM(tc.size, tc.romType))
+		for addr, block := range tc.mirrors {
+			// the open bus is set to a value which is not in the ROM
+			mem.SetByteBank(0xFF, 0x00, 0x0000)
+			assert.Equal(t, block, mem.GetByte(addr), "%s: $%06X", tc.name, addr)
+		}
+	}
+
+	// the lower halves of the other LoROM banks are not mapped to the ROM
+	mem := newMemory()
+	mem.LoadROM(newROM(0x100000, rom.LoROM))
+	mem.SetByteBank(0xFF, 0x00, 0x0000)
+	assert.EqualValues(t, 0xFF, mem.GetByteBank(0x70, 0x0000))
+}
+
+// newTestConsole returns a CPU connected to the PPU and the APU through the memory, like in the emulator
+func newTestConsole(dotRenderer bool) *CPU {
+	rf := io.NewRegisterFactory()
+	ppu := newPPU(&render.NoOpRenderer{}, rf, dotRenderer)
+	mem := newTestMemory()
+	cpu := newCPU(mem, rf)
+	cpu.ppu, ppu.cpu = ppu, cpu
+	mem.cpu, mem.ppu, mem.apu = cpu, ppu, apu.New(rf)
+	mem.initIo(rf)
+	return cpu
+}
+
+func TestOpenBus(t *testing.T) {
+	cpu := newTestConsole(false)
+	mem, ppu := cpu.memory, cpu.ppu
+
+	// Unused and write-only registers return the last value on the data bus
+	mem.SetByteBank(0x5A, 0x00, 0x0010)
+	assert.EqualValues(t, 0x5A, mem.GetByteBank(0x00, 0x2000))
+	assert.EqualValues(t, 0x5A, mem.GetByteBank(0x00, 0x4000))
+	assert.EqualValues(t, 0x5A, mem.GetByteBank(0x00, 0x2100))
+	assert.EqualValues(t, 0x5A, mem.GetByteBank(0x00, 0x2137))
+	mem.GetByteBank(0x00, 0x0010)
+	assert.EqualValues(t, 0x5A, mem.GetByteBank(0x00, 0x6000))
+
+	// The undefined bits of the CPU registers are open bus
+	mem.SetByteBank(0xFF, 0x00, 0x0010)
+	assert.EqualValues(t, 0x72, mem.GetByteBank(0x00, 0x4210))
+	assert.EqualValues(t, 0x72, mem.GetByteBank(0x00, 0x4211))
+	assert.EqualValues(t, 0x32, mem.GetByteBank(0x00, 0x4212)&0x3F)
+	mem.SetByteBank(0xA5, 0x00, 0x0010)
+	assert.EqualValues(t, 0xA4, mem.GetByteBank(0x00, 0x4016))
+	assert.EqualValues(t, 0xBC, mem.GetByteBank(0x00, 0x4017))
+
+	// Some write-only PPU1 registers return the last value read from PPU1
+	ppu.m7.signedMutlResult = 0x123456
+	mem.SetByteBank(0x00, 0x00, 0x0010)
+	assert.EqualValues(t, 0x34, mem.GetByteBank(0x00, 0x2135))
+	assert.EqualValues(t, 0x34, mem.GetByteBank(0x00, 0x2104))
+	assert.EqualValues(t, 0x34, mem.GetByteBank(0x00, 0x2129))
+	assert.EqualValues(t, 0x11, mem.GetByteBank(0x00, 0x213E))
+	assert.EqualValues(t, 0x11, mem.GetByteBank(0x00, 0x2105))
+	assert.EqualValues(t, 0x11, mem.GetByteBank(0x00, 0x2107))
+
+	// The undefined bits of the PPU2 registers return the last value read from PPU2
+	ppu.status.hCounterLatch = 0x1E0
+	assert.EqualValues(t, 0xE0, mem.GetByteBank(0x00, 0x213C))
+	assert.EqualValues(t, 0xE1, mem.GetByteBank(0x00, 0x213C))
+	assert.EqualValues(t, 0x20, mem.GetByteBank(0x00, 0x213F)&0x20)
+}
+
+func TestAccessCycles(t *testing.T) {
+	cpu := newCPU(newFlatMemory(), io.NewRegisterFactory())
+	mem := cpu.memory
+
+	for addr, cycles := range map[uint32]uint16{
+		0x000000: 8, 0x001FFF: 8, 0x002100: 6, 0x003FFF: 6, 0x004016: 12, 0x0041FF: 12,
+		0x004200: 6, 0x005FFF: 6, 0x006000: 8, 0x008000: 8, 0x400000: 8, 0x7E2000: 8,
+		0x802100: 6, 0x804016: 12, 0x808000: 8, 0xC00000: 8,
+	} {
+		assert.Equalf(t, cycles, mem.accessCycles(uint8(addr>>16), uint16(addr)), "address: %06X", addr)
+	}
+
+	// MEMSEL speeds up the ROM area of banks 80-FF only
+	cpu.memsel(0x01)
+	for addr, cycles := range map[uint32]uint16{0x008000: 8, 0x400000: 8, 0x800000: 8, 0x808000: 6, 0xC00000: 6, 0xFFFFFF: 6} {
+		assert.Equalf(t, cycles, mem.accessCycles(uint8(addr>>16), uint16(addr)), "address: %06X", addr)
+	}
+
+	// A NOP is an opcode fetch followed by an internal operation of 6 master cycles
+	mem.SetByte(0xEA, 0x808000)
+	cpu.K, cpu.PC = 0x80, 0x8000
+	cpu.execOpcode()
+	assert.EqualValues(t, 12, cpu.cycles)
+
+	cpu.memsel(0x00)
+	cpu.PC = 0x8000
+	cpu.execOpcode()
+	assert.EqualValues(t, 12+14, cpu.cycles)
+	assert.EqualValues(t, 12+14, cpu.elapsed)
+}
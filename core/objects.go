@@ -1,6 +1,10 @@
 package core
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/snes-emu/gose/bit"
+)
 
 //baseTile represents an 8x8 pixels tile
 type baseTile struct {
@@ -9,8 +13,9 @@ type baseTile struct {
 	// index of the color palette to use.
 	// for background tiles, the number of entries in the palette depends on the mode and the background)
 	// there are 16 available color palettes, but only 8 available to sprites
-	palette    uint8
-	colorDepth uint8 // number of bits used to addres the colors
+	palette     uint8
+	colorDepth  uint8 // number of bits used to addres the colors
+	directColor bool  // colors are encoded in the color index and the palette bits instead of being read from the CGRAM
 }
 
 //baseTileSize returns the size of a base tile in bytes depending on its color depth
@@ -29,12 +34,28 @@ type bgTile struct {
 	hSize, vSize uint16 // horizontal and vertical size
 }
 
+// tileAt returns the 8x8 tile at the given coordinate in the background tile
+// 16x16 tiles are made of the tiles N, N+1, N+16 and N+17 where N is the tile number found in the tilemap
 func (bgt *bgTile) tileAt(xTile, yTile uint16) baseTile {
 	return baseTile{
-		addr:       bgt.addr + (xTile+(yTile<<4))*baseTileSize(bgt.colorDepth),
-		colorDepth: bgt.colorDepth,
-		palette:    bgt.palette,
+		addr:        bgt.addr + (xTile+(yTile<<4))*baseTileSize(bgt.colorDepth),
+		colorDepth:  bgt.colorDepth,
+		palette:     bgt.palette,
+		directColor: bgt.directColor,
+	}
+}
+
+// pixelAt returns the 8x8 tile containing the given pixel of the background tile and the coordinates of the pixel in this tile
+// flips are applied on the whole background tile, for 16x16 tiles the 8x8 tiles are swapped as well
+func (bgt *bgTile) pixelAt(x, y uint16) (baseTile, uint16, uint16) {
+	if bgt.hFlip {
+		x = bgt.hSize - 1 - x
+	}
+	if bgt.vFlip {
+		y = bgt.vSize - 1 - y
 	}
+
+	return bgt.tileAt(x/TILE_SIZE, y/TILE_SIZE), x % TILE_SIZE, y % TILE_SIZE
 }
 
 // sprite defines how a sprite is handled by the super-nes
@@ -57,15 +78,61 @@ type sprite struct {
 	hSize, vSize uint16 // horizontal and vertical sizes
 }
 
-// return true if the given sprite intersects the v-line
-func (s *sprite) IntersectsLine(vCounter uint16) bool {
-	return vCounter >= s.y && vCounter < s.y+s.vSize
+// rowAt returns the row of the sprite displayed on the given line and whether the sprite is on that line at all.
+// The Y coordinate wraps around at 256 so sprites can start at the bottom of the screen and continue at the top.
+// In OBJ interlace mode sprites are displayed half-sized: every line only shows the even or odd rows
+// of the sprite depending on the current field.
+// The returned row already takes the vertical flip into account. Rectangular sprites (16x32 and 32x64)
+// are flipped as two independent square halves, just like the hardware does.
+func (s *sprite) rowAt(line uint16, objInterlace bool, oddField bool) (uint16, bool) {
+	height := s.vSize
+	if objInterlace {
+		height >>= 1
+	}
+
+	row := (line - s.y) & 0xFF
+	if row >= height {
+		return 0, false
+	}
+
+	if objInterlace {
+		row = row<<1 | bit.BoolToUint16(oddField)
+	}
+
+	if s.vFlip {
+		switch {
+		case s.hSize == s.vSize:
+			row = s.vSize - 1 - row
+		case row < s.hSize:
+			row = s.hSize - 1 - row
+		default:
+			row = s.hSize + (s.hSize - 1) - (row - s.hSize)
+		}
+	}
+
+	return row, true
+}
+
+// onScreen returns true if some part of the sprite is horizontally visible
+// X coordinates are 9-bit values, anything above 256 is displayed on the left of the screen (negative coordinates)
+// Note that a sprite at X=256 is considered to be on screen by the hardware even if it is not visible
+func (s *sprite) onScreen() bool {
+	return s.x <= 256 || s.x+s.hSize-1 >= 512
 }
 
 // tileAt returns the tileAt at the given coordinate in the sprite
+// The tile index wraps inside the 16x16 tiles name table: the tile to the right of tile 0x0F is tile 0x00
+// and the tile below tile 0xF0 is tile 0x00
 func (s *sprite) tileAt(xTile uint16, yTile uint16) baseTile {
+	// Each name table holds 256 tiles of 32 bytes
+	table := s.addr &^ 0x1FFF
+	tile := (s.addr & 0x1FFF) >> 5
+
+	col := (tile + xTile) & 0xF
+	row := ((tile >> 4) + yTile) & 0xF
+
 	return baseTile{
-		addr:       s.addr + (yTile<<4+xTile)*baseTileSize(4),
+		addr:       table + (row<<4|col)*baseTileSize(4),
 		colorDepth: s.colorDepth,
 		palette:    s.palette,
 	}
@@ -33,3 +33,46 @@ func TestSpriteSizeTable(t *testing.T) {
 		}
 	}
 }
+
+func TestSpriteRowAt(t *testing.T) {
+	testCases := []struct {
+		sprite       sprite
+		line         uint16
+		objInterlace bool
+		oddField     bool
+		row          uint16
+		ok           bool
+	}{
+		{sprite: sprite{y: 10, hSize: 16, vSize: 16}, line: 9, ok: false},
+		{sprite: sprite{y: 10, hSize: 16, vSize: 16}, line: 12, row: 2, ok: true},
+		{sprite: sprite{y: 10, hSize: 16, vSize: 16}, line: 26, ok: false},
+		// Y wraps around at 256
+		{sprite: sprite{y: 250, hSize: 16, vSize: 16}, line: 3, row: 9, ok: true},
+		{sprite: sprite{y: 10, hSize: 16, vSize: 16, vFlip: true}, line: 12, row: 13, ok: true},
+		// Rectangular sprites flip both square halves independently
+		{sprite: sprite{y: 0, hSize: 16, vSize: 32, vFlip: true}, line: 0, row: 15, ok: true},
+		{sprite: sprite{y: 0, hSize: 16, vSize: 32, vFlip: true}, line: 16, row: 31, ok: true},
+		{sprite: sprite{y: 0, hSize: 16, vSize: 32, vFlip: true}, line: 20, row: 27, ok: true},
+		// OBJ interlace halves the displayed height
+		{sprite: sprite{y: 0, hSize: 16, vSize: 16}, line: 3, objInterlace: true, oddField: true, row: 7, ok: true},
+		{sprite: sprite{y: 0, hSize: 16, vSize: 16}, line: 8, objInterlace: true, ok: false},
+	}
+
+	for i, tc := range testCases {
+		row, ok := tc.sprite.rowAt(tc.line, tc.objInterlace, tc.oddField)
+		assert.Equalf(t, tc.ok, ok, "test case %d", i)
+		if tc.ok {
+			assert.Equalf(t, tc.row, row, "test case %d", i)
+		}
+	}
+}
+
+func TestSpriteTileAtWraps(t *testing.T) {
+	// Tile 0x1F in the second name table of a base address at 0x4000
+	s := sprite{baseTile: baseTile{addr: 0x4000 + 0x2000 + 0x1F<<5, colorDepth: 4}}
+
+	assert.EqualValues(t, 0x6000+0x10<<5, s.tileAt(1, 0).addr)
+	assert.EqualValues(t, 0x6000+0x2F<<5, s.tileAt(0, 1).addr)
+	s.addr = 0x6000 + 0xFF<<5
+	assert.EqualValues(t, 0x6000+0x00<<5, s.tileAt(1, 1).addr)
+}
@@ -1,8 +1,6 @@
 package core
 
 import (
-	"os"
-
 	"github.com/snes-emu/gose/log"
 
 	"github.com/snes-emu/gose/bit"
@@ -45,6 +43,9 @@ func (cpu *CPU) abort() {
 }
 
 func (cpu *CPU) nmi() {
+	// the opcode and signature cycles of BRK are replaced by internal operations
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister())
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister())
 	addressLo, addressHi := bit.SplitUint16(cpu.getPCRegister())
 	if cpu.eFlag {
 		cpu.pushStack(addressHi)
@@ -66,15 +67,18 @@ func (cpu *CPU) nmi() {
 	}
 	cpu.dFlag = false
 	cpu.iFlag = true
-
+	cpu.step(8 - bit.BoolToUint16(cpu.eFlag))
 }
 
 func (cpu *CPU) reset() {
+	cpu.waiting = false
+	cpu.stopped = false
 	cpu.setEFlag(true)
 	cpu.D = 0x0000
 	cpu.DBR = 0x00
 	cpu.K = 0x00
 	cpu.S = 0x01FF
+	cpu.memory.fastROM = false
 	addressLo := cpu.memory.GetByteBank(0x00, resetEmulationVector)
 	addressHi := cpu.memory.GetByteBank(0x00, resetEmulationVector+1)
 	cpu.PC = bit.JoinUint16(addressLo, addressHi)
@@ -84,6 +88,9 @@ func (cpu *CPU) reset() {
 }
 
 func (cpu *CPU) irq() {
+	// the opcode and signature cycles of BRK are replaced by internal operations
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister())
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister())
 	addressLo, addressHi := bit.SplitUint16(cpu.getPCRegister())
 	if cpu.eFlag {
 		cpu.pushStack(addressHi)
@@ -105,7 +112,7 @@ func (cpu *CPU) irq() {
 	}
 	cpu.dFlag = false
 	cpu.iFlag = true
-
+	cpu.step(8 - bit.BoolToUint16(cpu.eFlag))
 }
 
 // bit16 performs a bitwise and for 16bits variables
@@ -151,7 +158,7 @@ func (cpu *CPU) op24() {
 	dataLo, dataHi := cpu.admDirect()
 	cpu.bit(dataLo, dataHi, false)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op2C() {
@@ -165,7 +172,7 @@ func (cpu *CPU) op34() {
 	dataLo, dataHi := cpu.admDirectX()
 	cpu.bit(dataLo, dataHi, false)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op3C() {
@@ -183,6 +190,13 @@ func (cpu *CPU) op89() {
 }
 
 func (cpu *CPU) branch(cond bool, offset uint16) {
+	cpu.setBranchPFlag(offset)
+	if cond {
+		cpu.idleAfterOpcode()
+		if cpu.eFlag && cpu.pFlag {
+			cpu.idleAfterOpcode()
+		}
+	}
 	cpu.PC += offset*bit.BoolToUint16(cond) + 2
 	cpu.step(2 + bit.BoolToUint16(cond) + bit.BoolToUint16(cond)*bit.BoolToUint16(cpu.eFlag)*bit.BoolToUint16(cpu.pFlag))
 }
@@ -235,7 +249,19 @@ func (cpu *CPU) op10() {
 	cpu.bpl(cpu.admRelative8())
 }
 
+// setBranchPFlag sets the page boundary crossed flag if the branch target is not in the page of the next instruction,
+// which costs an extra cycle in emulation mode
+func (cpu *CPU) setBranchPFlag(offset uint16) {
+	next := cpu.getPCRegister() + 2
+	cpu.pFlag = (next+offset)&0xFF00 != next&0xFF00
+}
+
 func (cpu *CPU) bra(offset uint16) {
+	cpu.setBranchPFlag(offset)
+	cpu.idleAfterOpcode()
+	if cpu.eFlag && cpu.pFlag {
+		cpu.idleAfterOpcode()
+	}
 	cpu.PC += offset + 2
 	cpu.step(3 + bit.BoolToUint16(cpu.eFlag)*bit.BoolToUint16(cpu.pFlag))
 }
@@ -261,6 +287,7 @@ func (cpu *CPU) op70() {
 }
 
 func (cpu *CPU) brl(offset uint16) {
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister()+2)
 	cpu.PC += offset + 3
 	cpu.step(4)
 }
@@ -269,14 +296,48 @@ func (cpu *CPU) op82() {
 	cpu.brl(cpu.admRelative16())
 }
 
+// decimalAdd adds data and the carry to value one BCD digit at a time, the width of the operands is given by their number of digits
+// A subtraction is performed by adding the complement of data, in which case the digits are adjusted when they borrow.
+// Like the hardware, the digits are added even if they are not valid BCD digits (A to F), and the overflow
+// is computed from the binary result before the adjustment of the last digit.
+// It returns the result, the carry and the overflow.
+// See: https://wiki.superfamicom.org/65816-reference#decimal-mode
+func decimalAdd(value, data int, carry bool, digits uint, subtract bool) (int, bool, bool) {
+	data &= 1<<(4*digits) - 1
+
+	var result int
+	var overflow bool
+	for d := uint(0); d < digits; d++ {
+		shift := 4 * d
+		max := 1<<(shift+4) - 1 // largest value of the digits up to the current one
+		mask := 0xF << shift
+
+		result = value&mask + data&mask + int(bit.BoolToUint8(carry))<<shift + result&(1<<shift-1)
+		if d == digits-1 {
+			overflow = ^(value^data)&(value^result)&(1<<(shift+3)) != 0
+		}
+
+		if subtract && result <= max {
+			result -= 6 << shift
+		} else if !subtract && result > max-6<<shift {
+			result += 6 << shift
+		}
+		carry = result > max
+	}
+
+	return result & (1<<(4*digits) - 1), carry, overflow
+}
+
 // adc16 performs an add with carry 16bit operation the formula is: accumulator = accumulator + data + carry
 func (cpu *CPU) adc16(data uint16) uint16 {
 	var result uint16
 	if cpu.dFlag {
 		// Decimal mode on -> BCD arithmetic used
-		panic("TODO, d flag in adc needs to be implemented")
-		//result = (cpu.getCRegister() & 0x000f) + (data & 0x000f) + bit.BoolToUint16(cpu.cFlag) + (cpu.getCRegister() & 0x00f0) + (data & 0x00f0) + (cpu.C & 0x0f00) + (data & 0x0f00) + (cpu.getCRegister() & 0xf000) + (data & 0xf000)
-
+		var res int
+		res, cpu.cFlag, cpu.vFlag = decimalAdd(int(cpu.getCRegister()), int(data), cpu.cFlag, 4, false)
+		result = uint16(res)
+		cpu.nFlag = result&0x8000 != 0
+		cpu.zFlag = result == 0
 	} else {
 		// Decimal mode off -> binary arithmetic used
 		result = cpu.getCRegister() + data + bit.BoolToUint16(cpu.cFlag)
@@ -298,7 +359,11 @@ func (cpu *CPU) adc8(data uint8) uint8 {
 	var result uint8
 	if cpu.dFlag {
 		// Decimal mode on -> BCD arithmetic used
-		panic("TODO, d flag in adc needs to be implemented")
+		var res int
+		res, cpu.cFlag, cpu.vFlag = decimalAdd(int(cpu.getARegister()), int(data), cpu.cFlag, 2, false)
+		result = uint8(res)
+		cpu.nFlag = result&0x80 != 0
+		cpu.zFlag = result == 0
 	} else {
 		// Decimal mode off -> binary arithmetic used
 		result = cpu.getARegister() + data + bit.BoolToUint8(cpu.cFlag)
@@ -329,7 +394,7 @@ func (cpu *CPU) op61() {
 	dataLo, dataHi := cpu.admPDirectX()
 	cpu.adc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op63() {
@@ -345,7 +410,7 @@ func (cpu *CPU) op65() {
 	dataLo, dataHi := cpu.admDirect()
 	cpu.adc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op67() {
@@ -353,7 +418,7 @@ func (cpu *CPU) op67() {
 	dataLo, dataHi := cpu.admBDirect()
 	cpu.adc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op69() {
@@ -385,7 +450,7 @@ func (cpu *CPU) op71() {
 	dataLo, dataHi := cpu.admPDirectY()
 	cpu.adc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0) - bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0) - bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
 }
 
 func (cpu *CPU) op72() {
@@ -394,7 +459,7 @@ func (cpu *CPU) op72() {
 	cpu.adc(dataLo, dataHi)
 
 	cpu.PC += 2
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op73() {
@@ -411,7 +476,7 @@ func (cpu *CPU) op75() {
 	cpu.adc(dataLo, dataHi)
 
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op77() {
@@ -419,7 +484,7 @@ func (cpu *CPU) op77() {
 	dataLo, dataHi := cpu.admDirectX()
 	cpu.adc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op79() {
@@ -436,7 +501,7 @@ func (cpu *CPU) op7D() {
 	dataLo, dataHi := cpu.admAbsoluteY()
 	cpu.adc(dataLo, dataHi)
 	cpu.PC += 3
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0) - bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0) - bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
 }
 
 func (cpu *CPU) op7F() {
@@ -451,8 +516,11 @@ func (cpu *CPU) sbc16(data uint16) uint16 {
 	var result uint16
 	if cpu.dFlag {
 		// Decimal mode on -> BCD arithmetic used
-		panic("TODO, d flag in sbc needs to be implemented")
-
+		var res int
+		res, cpu.cFlag, cpu.vFlag = decimalAdd(int(cpu.getCRegister()), int(^data), cpu.cFlag, 4, true)
+		result = uint16(res)
+		cpu.nFlag = result&0x8000 != 0
+		cpu.zFlag = result == 0
 	} else {
 		// Decimal mode off -> binary arithmetic used
 		result = cpu.getCRegister() - data - 1 + bit.BoolToUint16(cpu.cFlag)
@@ -474,8 +542,11 @@ func (cpu *CPU) sbc8(data uint8) uint8 {
 	var result uint8
 	if cpu.dFlag {
 		// Decimal mode on -> BCD arithmetic used
-		panic("TODO, d flag in sbc needs to be implemented")
-
+		var res int
+		res, cpu.cFlag, cpu.vFlag = decimalAdd(int(cpu.getARegister()), int(^data), cpu.cFlag, 2, true)
+		result = uint8(res)
+		cpu.nFlag = result&0x80 != 0
+		cpu.zFlag = result == 0
 	} else {
 		// Decimal mode off -> binary arithmetic used
 		result = cpu.getARegister() - data - 1 + bit.BoolToUint8(cpu.cFlag)
@@ -506,7 +577,7 @@ func (cpu *CPU) opE1() {
 	dataLo, dataHi := cpu.admPDirectX()
 	cpu.sbc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opE3() {
@@ -522,7 +593,7 @@ func (cpu *CPU) opE5() {
 	dataLo, dataHi := cpu.admDirect()
 	cpu.sbc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opE7() {
@@ -530,7 +601,7 @@ func (cpu *CPU) opE7() {
 	dataLo, dataHi := cpu.admBDirect()
 	cpu.sbc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opE9() {
@@ -562,7 +633,7 @@ func (cpu *CPU) opF1() {
 	dataLo, dataHi := cpu.admPDirectY()
 	cpu.sbc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0) - bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0) - bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
 }
 
 func (cpu *CPU) opF2() {
@@ -571,7 +642,7 @@ func (cpu *CPU) opF2() {
 	cpu.sbc(dataLo, dataHi)
 
 	cpu.PC += 2
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opF3() {
@@ -588,7 +659,7 @@ func (cpu *CPU) opF5() {
 	cpu.sbc(dataLo, dataHi)
 
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opF7() {
@@ -596,7 +667,7 @@ func (cpu *CPU) opF7() {
 	dataLo, dataHi := cpu.admDirectX()
 	cpu.sbc(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opF9() {
@@ -613,7 +684,7 @@ func (cpu *CPU) opFD() {
 	dataLo, dataHi := cpu.admAbsoluteY()
 	cpu.sbc(dataLo, dataHi)
 	cpu.PC += 3
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0) - bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0) - bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
 }
 
 func (cpu *CPU) opFF() {
@@ -661,7 +732,7 @@ func (cpu *CPU) opC1() {
 	dataLo, dataHi := cpu.admPDirectX()
 	cpu.cmp(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opC3() {
@@ -677,7 +748,7 @@ func (cpu *CPU) opC5() {
 	dataLo, dataHi := cpu.admDirect()
 	cpu.cmp(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opC7() {
@@ -685,7 +756,7 @@ func (cpu *CPU) opC7() {
 	dataLo, dataHi := cpu.admBDirect()
 	cpu.cmp(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opC9() {
@@ -717,7 +788,7 @@ func (cpu *CPU) opD1() {
 	dataLo, dataHi := cpu.admPDirectY()
 	cpu.cmp(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0) + bit.BoolToUint16(cpu.xFlag)*(bit.BoolToUint16(cpu.pFlag)-1))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0) + bit.BoolToUint16(cpu.xFlag)*(bit.BoolToUint16(cpu.pFlag)-1))
 }
 
 func (cpu *CPU) opD2() {
@@ -725,7 +796,7 @@ func (cpu *CPU) opD2() {
 	dataLo, dataHi := cpu.admPDirect()
 	cpu.cmp(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opD3() {
@@ -741,7 +812,7 @@ func (cpu *CPU) opD5() {
 	dataLo, dataHi := cpu.admDirectX()
 	cpu.cmp(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opD7() {
@@ -749,7 +820,7 @@ func (cpu *CPU) opD7() {
 	dataLo, dataHi := cpu.admBDirectY()
 	cpu.cmp(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opD9() {
@@ -815,14 +886,14 @@ func (cpu *CPU) opE0() {
 }
 
 func (cpu *CPU) opE4() {
-	dataLo, dataHi := cpu.admDirect()
+	dataLo, dataHi := cpu.readIndex(cpu.admDirectP())
 	cpu.cpx(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opEC() {
-	dataLo, dataHi := cpu.admAbsolute()
+	dataLo, dataHi := cpu.readIndex(cpu.admAbsoluteP())
 	cpu.cpx(dataLo, dataHi)
 	cpu.PC += 3
 	cpu.step(5 - bit.BoolToUint16(cpu.xFlag))
@@ -867,14 +938,14 @@ func (cpu *CPU) opC0() {
 }
 
 func (cpu *CPU) opC4() {
-	dataLo, dataHi := cpu.admDirect()
+	dataLo, dataHi := cpu.readIndex(cpu.admDirectP())
 	cpu.cpy(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opCC() {
-	dataLo, dataHi := cpu.admAbsolute()
+	dataLo, dataHi := cpu.readIndex(cpu.admAbsoluteP())
 	cpu.cpy(dataLo, dataHi)
 	cpu.PC += 3
 	cpu.step(5 - bit.BoolToUint16(cpu.xFlag))
@@ -923,7 +994,7 @@ func (cpu *CPU) op3A() {
 //opC6 performs a decrement operation on memory through direct addressing mode
 func (cpu *CPU) opC6() {
 	laddr, haddr := cpu.admDirectP()
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
 		cpu.memory.SetByte(cpu.dec8(dataLo), laddr)
 	} else {
@@ -932,13 +1003,13 @@ func (cpu *CPU) opC6() {
 		cpu.memory.SetByte(resultLo, laddr)
 	}
 	cpu.PC += 2
-	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 //opCE performs a decrement operation on memory through the absolute addressing mode
 func (cpu *CPU) opCE() {
 	laddr, haddr := cpu.admAbsoluteP()
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
 		cpu.memory.SetByte(cpu.dec8(dataLo), laddr)
 	} else {
@@ -953,7 +1024,7 @@ func (cpu *CPU) opCE() {
 //opD6 performs a decrement operation on memory through direct,X addressing mode
 func (cpu *CPU) opD6() {
 	laddr, haddr := cpu.admDirectXP()
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
 		cpu.memory.SetByte(cpu.dec8(dataLo), laddr)
 	} else {
@@ -962,13 +1033,13 @@ func (cpu *CPU) opD6() {
 		cpu.memory.SetByte(resultLo, laddr)
 	}
 	cpu.PC += 2
-	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 //opDE performs a decrement operation on memory through absolute,X addressing mode
 func (cpu *CPU) opDE() {
 	laddr, haddr := cpu.admAbsoluteXP()
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
 		cpu.memory.SetByte(cpu.dec8(dataLo), laddr)
 	} else {
@@ -1063,7 +1134,7 @@ func (cpu *CPU) op1A() {
 //opE6 performs a increment operation on memory through direct addressing mode
 func (cpu *CPU) opE6() {
 	laddr, haddr := cpu.admDirectP()
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
 		cpu.memory.SetByte(cpu.inc8(dataLo), laddr)
 	} else {
@@ -1072,13 +1143,13 @@ func (cpu *CPU) opE6() {
 		cpu.memory.SetByte(resultLo, laddr)
 	}
 	cpu.PC += 2
-	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 //opEE performs a increment operation through the absolute access mode
 func (cpu *CPU) opEE() {
 	laddr, haddr := cpu.admAbsoluteP()
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
 		cpu.memory.SetByte(cpu.inc8(dataLo), laddr)
 	} else {
@@ -1093,7 +1164,7 @@ func (cpu *CPU) opEE() {
 //opF6 performs a increment operation on memory through direct,X addressing mode
 func (cpu *CPU) opF6() {
 	laddr, haddr := cpu.admDirectXP()
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
 		cpu.memory.SetByte(cpu.inc8(dataLo), laddr)
 	} else {
@@ -1102,13 +1173,13 @@ func (cpu *CPU) opF6() {
 		cpu.memory.SetByte(resultLo, laddr)
 	}
 	cpu.PC += 2
-	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 //opF6 performs a increment operation on memory through absolute,X addressing mode
 func (cpu *CPU) opFE() {
 	laddr, haddr := cpu.admAbsoluteXP()
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
 		cpu.memory.SetByte(cpu.inc8(dataLo), laddr)
 	} else {
@@ -1302,19 +1373,23 @@ func (cpu *CPU) opE2() {
 	cpu.sep()
 }
 
-// stp STP stops the clock input of the 65C816,
+// stp stops the clock input of the 65C816 until the next reset, the rest of the console keeps running
 func (cpu *CPU) stp() {
-	log.Info("CPU has been shutdown")
-	os.Exit(0)
+	log.Info("CPU has been stopped")
+	cpu.stopped = true
+	cpu.PC++
+	cpu.step(3)
 }
 
 func (cpu *CPU) opDB() {
 	cpu.stp()
 }
 
-// wai stops the clock input of the 65C816,
+// wai stops the clock input of the 65C816 until an interrupt is requested (see execOpcode)
 func (cpu *CPU) wai() {
 	cpu.waiting = true
+	cpu.PC++
+	cpu.step(3)
 }
 
 func (cpu *CPU) opCB() {
@@ -1364,6 +1439,7 @@ func (cpu *CPU) opDC() {
 
 // jsl jumps to a subroutine long
 func (cpu *CPU) jsl(laddr uint16, haddr uint8) {
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister()+3)
 	laddr2, haddr2 := bit.SplitUint16(cpu.getPCRegister() + 3)
 	cpu.pushStackNew24(laddr2, haddr2, cpu.getKRegister())
 
@@ -1373,11 +1449,12 @@ func (cpu *CPU) jsl(laddr uint16, haddr uint8) {
 func (cpu *CPU) op22() {
 	laddr, haddr := cpu.admLongJ()
 	cpu.jsl(laddr, haddr)
-	cpu.step(3)
+	cpu.step(8)
 }
 
 // jsr jumps to a subroutine
 func (cpu *CPU) jsr(addr uint16) {
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister()+2)
 	laddr, haddr := bit.SplitUint16(cpu.getPCRegister() + 2)
 
 	cpu.pushStack(haddr)
@@ -1439,7 +1516,7 @@ func (cpu *CPU) opA1() {
 	dataLo, dataHi := cpu.admPDirectX()
 	cpu.lda(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opA3() {
@@ -1453,14 +1530,14 @@ func (cpu *CPU) opA5() {
 	dataLo, dataHi := cpu.admDirect()
 	cpu.lda(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opA7() {
 	dataLo, dataHi := cpu.admBDirect()
 	cpu.lda(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opA9() {
@@ -1488,14 +1565,14 @@ func (cpu *CPU) opB1() {
 	dataLo, dataHi := cpu.admPDirectY()
 	cpu.lda(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0) - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0) - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
 }
 
 func (cpu *CPU) opB2() {
 	dataLo, dataHi := cpu.admPDirect()
 	cpu.lda(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opB3() {
@@ -1509,14 +1586,14 @@ func (cpu *CPU) opB5() {
 	dataLo, dataHi := cpu.admDirectX()
 	cpu.lda(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opB7() {
 	dataLo, dataHi := cpu.admBDirectY()
 	cpu.lda(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opB9() {
@@ -1577,28 +1654,28 @@ func (cpu *CPU) opA2() {
 }
 
 func (cpu *CPU) opA6() {
-	dataLo, dataHi := cpu.admDirect()
+	dataLo, dataHi := cpu.readIndex(cpu.admDirectP())
 	cpu.ldx(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opAE() {
-	dataLo, dataHi := cpu.admAbsolute()
+	dataLo, dataHi := cpu.readIndex(cpu.admAbsoluteP())
 	cpu.ldx(dataLo, dataHi)
 	cpu.PC += 3
 	cpu.step(5 - bit.BoolToUint16(cpu.xFlag))
 }
 
 func (cpu *CPU) opB6() {
-	dataLo, dataHi := cpu.admDirectY()
+	dataLo, dataHi := cpu.readIndex(cpu.admDirectYP())
 	cpu.ldx(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opBE() {
-	dataLo, dataHi := cpu.admAbsoluteY()
+	dataLo, dataHi := cpu.readIndex(cpu.admAbsoluteIndexedP(cpu.getYRegister(), false))
 	cpu.ldx(dataLo, dataHi)
 	cpu.PC += 3
 	cpu.step(6 - 2*bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
@@ -1641,28 +1718,28 @@ func (cpu *CPU) opA0() {
 }
 
 func (cpu *CPU) opA4() {
-	dataLo, dataHi := cpu.admDirect()
+	dataLo, dataHi := cpu.readIndex(cpu.admDirectP())
 	cpu.ldy(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opAC() {
-	dataLo, dataHi := cpu.admAbsolute()
+	dataLo, dataHi := cpu.readIndex(cpu.admAbsoluteP())
 	cpu.ldy(dataLo, dataHi)
 	cpu.PC += 3
 	cpu.step(5 - bit.BoolToUint16(cpu.xFlag))
 }
 
 func (cpu *CPU) opB4() {
-	dataLo, dataHi := cpu.admDirectX()
+	dataLo, dataHi := cpu.readIndex(cpu.admDirectXP())
 	cpu.ldy(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) opBC() {
-	dataLo, dataHi := cpu.admAbsoluteX()
+	dataLo, dataHi := cpu.readIndex(cpu.admAbsoluteIndexedP(cpu.getXRegister(), false))
 	cpu.ldy(dataLo, dataHi)
 	cpu.PC += 3
 	cpu.step(6 - 2*bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
@@ -1696,7 +1773,7 @@ func (cpu *CPU) op81() {
 	laddr, haddr := cpu.admPDirectXP()
 	cpu.sta(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op83() {
@@ -1710,14 +1787,14 @@ func (cpu *CPU) op85() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.sta(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op87() {
 	laddr, haddr := cpu.admBDirectP()
 	cpu.sta(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op8D() {
@@ -1738,14 +1815,14 @@ func (cpu *CPU) op91() {
 	laddr, haddr := cpu.admPDirectYP()
 	cpu.sta(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op92() {
 	laddr, haddr := cpu.admPDirectP()
 	cpu.sta(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op93() {
@@ -1759,14 +1836,14 @@ func (cpu *CPU) op95() {
 	laddr, haddr := cpu.admDirectXP()
 	cpu.sta(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op97() {
 	laddr, haddr := cpu.admBDirectYP()
 	cpu.sta(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op99() {
@@ -1818,7 +1895,7 @@ func (cpu *CPU) op86() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.stx(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op8E() {
@@ -1832,7 +1909,7 @@ func (cpu *CPU) op96() {
 	laddr, haddr := cpu.admDirectYP()
 	cpu.stx(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 // sty16 stores the x register in the memory
@@ -1863,7 +1940,7 @@ func (cpu *CPU) op84() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.sty(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op8C() {
@@ -1877,7 +1954,7 @@ func (cpu *CPU) op94() {
 	laddr, haddr := cpu.admDirectXP()
 	cpu.sty(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 // stz16 stores 0 in the memory
@@ -1908,14 +1985,14 @@ func (cpu *CPU) op64() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.stz(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op74() {
 	laddr, haddr := cpu.admDirectXP()
 	cpu.stz(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op9C() {
@@ -1967,7 +2044,7 @@ func (cpu *CPU) op21() {
 	dataLo, dataHi := cpu.admPDirectX()
 	cpu.and(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op23() {
@@ -1981,14 +2058,14 @@ func (cpu *CPU) op25() {
 	dataLo, dataHi := cpu.admDirect()
 	cpu.and(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op27() {
 	dataLo, dataHi := cpu.admBDirect()
 	cpu.and(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op29() {
@@ -2016,14 +2093,14 @@ func (cpu *CPU) op31() {
 	dataLo, dataHi := cpu.admPDirectY()
 	cpu.and(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0) - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0) - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
 }
 
 func (cpu *CPU) op32() {
 	dataLo, dataHi := cpu.admPDirect()
 	cpu.and(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op33() {
@@ -2037,14 +2114,14 @@ func (cpu *CPU) op35() {
 	dataLo, dataHi := cpu.admDirectX()
 	cpu.and(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op37() {
 	dataLo, dataHi := cpu.admBDirectY()
 	cpu.and(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op39() {
@@ -2103,7 +2180,7 @@ func (cpu *CPU) op41() {
 	dataLo, dataHi := cpu.admPDirectX()
 	cpu.eor(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op43() {
@@ -2117,14 +2194,14 @@ func (cpu *CPU) op45() {
 	dataLo, dataHi := cpu.admDirect()
 	cpu.eor(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op47() {
 	dataLo, dataHi := cpu.admBDirect()
 	cpu.eor(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op49() {
@@ -2152,14 +2229,14 @@ func (cpu *CPU) op51() {
 	dataLo, dataHi := cpu.admPDirectY()
 	cpu.eor(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0) - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0) - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
 }
 
 func (cpu *CPU) op52() {
 	dataLo, dataHi := cpu.admPDirect()
 	cpu.eor(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op53() {
@@ -2173,14 +2250,14 @@ func (cpu *CPU) op55() {
 	dataLo, dataHi := cpu.admDirectX()
 	cpu.eor(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op57() {
 	dataLo, dataHi := cpu.admBDirectY()
 	cpu.eor(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op59() {
@@ -2239,7 +2316,7 @@ func (cpu *CPU) op01() {
 	dataLo, dataHi := cpu.admPDirectX()
 	cpu.ora(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op03() {
@@ -2253,14 +2330,14 @@ func (cpu *CPU) op05() {
 	dataLo, dataHi := cpu.admDirect()
 	cpu.ora(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(4 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op07() {
 	dataLo, dataHi := cpu.admBDirect()
 	cpu.ora(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op09() {
@@ -2288,14 +2365,14 @@ func (cpu *CPU) op11() {
 	dataLo, dataHi := cpu.admPDirectY()
 	cpu.ora(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0) - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0) - bit.BoolToUint16(cpu.xFlag) + bit.BoolToUint16(cpu.xFlag)*bit.BoolToUint16(cpu.pFlag))
 }
 
 func (cpu *CPU) op12() {
 	dataLo, dataHi := cpu.admPDirect()
 	cpu.ora(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(6 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op13() {
@@ -2309,14 +2386,14 @@ func (cpu *CPU) op15() {
 	dataLo, dataHi := cpu.admDirectX()
 	cpu.ora(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(5 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op17() {
 	dataLo, dataHi := cpu.admBDirectY()
 	cpu.ora(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op19() {
@@ -2403,6 +2480,8 @@ func (cpu *CPU) op42() {
 }
 
 func (cpu *CPU) rti() {
+	cpu.idleAfterOpcode()
+	cpu.idleAfterOpcode()
 	cpu.plp()
 	addressLo := cpu.pullStack()
 	addressHi := cpu.pullStack()
@@ -2419,6 +2498,8 @@ func (cpu *CPU) op40() {
 }
 
 func (cpu *CPU) rtl() {
+	cpu.idleAfterOpcode()
+	cpu.idleAfterOpcode()
 	PCLo, PCHi, K := cpu.pullStackNew24()
 	cpu.K = K
 	cpu.PC = bit.JoinUint16(PCLo, PCHi) + 1
@@ -2430,6 +2511,8 @@ func (cpu *CPU) op6B() {
 }
 
 func (cpu *CPU) rts() {
+	cpu.idleAfterOpcode()
+	cpu.idleAfterOpcode()
 	PCLo := cpu.pullStack()
 	PCHi := cpu.pullStack()
 	cpu.PC = bit.JoinUint16(PCLo, PCHi) + 1
@@ -2470,7 +2553,7 @@ func (cpu *CPU) asl8acc() {
 
 // asl16data performs a left shift on the 16 bit data
 func (cpu *CPU) asl16data(laddr, haddr uint32) {
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 
 	data := bit.JoinUint16(dataLo, dataHi)
 
@@ -2491,7 +2574,7 @@ func (cpu *CPU) asl16data(laddr, haddr uint32) {
 
 // asl8data performs a left shift on the 8 bit data
 func (cpu *CPU) asl8data(addr uint32) {
-	data := cpu.memory.GetByte(addr)
+	data, _ := cpu.readModifyData(addr, addr)
 
 	result := data << 1
 
@@ -2526,7 +2609,7 @@ func (cpu *CPU) op06() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.asl(laddr, haddr, false)
 	cpu.PC += 2
-	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op0A() {
@@ -2546,7 +2629,7 @@ func (cpu *CPU) op16() {
 	laddr, haddr := cpu.admDirectXP()
 	cpu.asl(laddr, haddr, false)
 	cpu.PC += 2
-	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op1E() {
@@ -2586,7 +2669,7 @@ func (cpu *CPU) lsr8acc() {
 
 // lsr16data performs a right shift on the 16 bit data
 func (cpu *CPU) lsr16data(haddr, laddr uint32) {
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 
 	data := bit.JoinUint16(dataLo, dataHi)
 
@@ -2607,7 +2690,7 @@ func (cpu *CPU) lsr16data(haddr, laddr uint32) {
 
 // lsr8data performs a right shift on the 8 bit data
 func (cpu *CPU) lsr8data(addr uint32) {
-	data := cpu.memory.GetByte(addr)
+	data, _ := cpu.readModifyData(addr, addr)
 
 	result := data >> 1
 
@@ -2642,7 +2725,7 @@ func (cpu *CPU) op46() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.lsr(laddr, haddr, false)
 	cpu.PC += 2
-	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op4A() {
@@ -2662,7 +2745,7 @@ func (cpu *CPU) op56() {
 	laddr, haddr := cpu.admDirectXP()
 	cpu.lsr(laddr, haddr, false)
 	cpu.PC += 2
-	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op5E() {
@@ -2710,7 +2793,7 @@ func (cpu *CPU) rol8acc() {
 
 // rol16data performs a rotate left on the 16 bit data
 func (cpu *CPU) rol16data(laddr, haddr uint32) {
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 
 	data := bit.JoinUint16(dataLo, dataHi)
 
@@ -2735,7 +2818,7 @@ func (cpu *CPU) rol16data(laddr, haddr uint32) {
 
 // rol8data performs a rotate left on the 8 bit data
 func (cpu *CPU) rol8data(addr uint32) {
-	data := cpu.memory.GetByte(addr)
+	data, _ := cpu.readModifyData(addr, addr)
 
 	result := data << 1
 
@@ -2774,7 +2857,7 @@ func (cpu *CPU) op26() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.rol(laddr, haddr, false)
 	cpu.PC += 2
-	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op2A() {
@@ -2794,7 +2877,7 @@ func (cpu *CPU) op36() {
 	laddr, haddr := cpu.admDirectXP()
 	cpu.rol(laddr, haddr, false)
 	cpu.PC += 2
-	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op3E() {
@@ -2842,7 +2925,7 @@ func (cpu *CPU) ror8acc() {
 
 // ror16data performs a rotate right on the 16 bit data
 func (cpu *CPU) ror16data(laddr, haddr uint32) {
-	dataLo, dataHi := cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 
 	data := bit.JoinUint16(dataLo, dataHi)
 
@@ -2867,7 +2950,7 @@ func (cpu *CPU) ror16data(laddr, haddr uint32) {
 
 // ror8data performs a rotate right on the 8 bit data
 func (cpu *CPU) ror8data(addr uint32) {
-	data := cpu.memory.GetByte(addr)
+	data, _ := cpu.readModifyData(addr, addr)
 
 	result := data << 1
 
@@ -2906,7 +2989,7 @@ func (cpu *CPU) op66() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.ror(laddr, haddr, false)
 	cpu.PC += 2
-	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op6A() {
@@ -2926,7 +3009,7 @@ func (cpu *CPU) op76() {
 	laddr, haddr := cpu.admDirectXP()
 	cpu.ror(laddr, haddr, false)
 	cpu.PC += 2
-	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(8 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op7E() {
@@ -2960,12 +3043,13 @@ func (cpu *CPU) opD4() {
 	dataLo, dataHi := cpu.admDirectNew()
 	cpu.pushStackNew16(dataLo, dataHi)
 	cpu.PC += 2
-	cpu.step(6 + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(6 + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 // PER instuction
 func (cpu *CPU) op62() {
 	dataLo, dataHi := cpu.admImmediate16()
+	cpu.idle(cpu.getKRegister(), cpu.getPCRegister()+2)
 	cpu.pushStackNew16(dataLo, dataHi)
 	cpu.PC += 3
 	cpu.step(6)
@@ -2991,6 +3075,7 @@ func (cpu *CPU) pha() {
 }
 
 func (cpu *CPU) op48() {
+	cpu.idleAfterOpcode()
 	cpu.pha()
 	cpu.PC++
 	cpu.step(4 - bit.BoolToUint16(cpu.mFlag))
@@ -2998,13 +3083,15 @@ func (cpu *CPU) op48() {
 
 // PHB instruction
 func (cpu *CPU) op8B() {
-	cpu.pushStackNew8(cpu.getDBRRegister())
+	cpu.idleAfterOpcode()
+	cpu.pushStack(cpu.getDBRRegister())
 	cpu.PC++
 	cpu.step(3)
 }
 
 // PHD instruction
 func (cpu *CPU) op0B() {
+	cpu.idleAfterOpcode()
 	cpu.pushStackNew16(bit.SplitUint16(cpu.getDRegister()))
 	cpu.PC++
 	cpu.step(4)
@@ -3012,7 +3099,8 @@ func (cpu *CPU) op0B() {
 
 // PHK instruction
 func (cpu *CPU) op4B() {
-	cpu.pushStackNew8(cpu.getKRegister())
+	cpu.idleAfterOpcode()
+	cpu.pushStack(cpu.getKRegister())
 	cpu.PC++
 	cpu.step(3)
 }
@@ -3034,6 +3122,7 @@ func (cpu *CPU) php() {
 }
 
 func (cpu *CPU) op08() {
+	cpu.idleAfterOpcode()
 	cpu.php()
 	cpu.PC++
 	cpu.step(3)
@@ -3059,6 +3148,7 @@ func (cpu *CPU) phx() {
 }
 
 func (cpu *CPU) opDA() {
+	cpu.idleAfterOpcode()
 	cpu.phx()
 	cpu.PC++
 	cpu.step(4 - bit.BoolToUint16(cpu.xFlag))
@@ -3084,6 +3174,7 @@ func (cpu *CPU) phy() {
 }
 
 func (cpu *CPU) op5A() {
+	cpu.idleAfterOpcode()
 	cpu.phy()
 	cpu.PC++
 	cpu.step(4 - bit.BoolToUint16(cpu.xFlag))
@@ -3121,6 +3212,8 @@ func (cpu *CPU) pla() {
 }
 
 func (cpu *CPU) op68() {
+	cpu.idleAfterOpcode()
+	cpu.idleAfterOpcode()
 	cpu.pla()
 	cpu.PC++
 	cpu.step(5 - bit.BoolToUint16(cpu.mFlag))
@@ -3128,6 +3221,8 @@ func (cpu *CPU) op68() {
 
 // PLB instruction
 func (cpu *CPU) opAB() {
+	cpu.idleAfterOpcode()
+	cpu.idleAfterOpcode()
 	cpu.DBR = cpu.pullStackNew8()
 	cpu.nFlag = cpu.getDBRRegister()&0x80 != 0
 	cpu.zFlag = cpu.getDBRRegister() == 0
@@ -3137,6 +3232,8 @@ func (cpu *CPU) opAB() {
 
 // PLD instruction
 func (cpu *CPU) op2B() {
+	cpu.idleAfterOpcode()
+	cpu.idleAfterOpcode()
 	cpu.D = bit.JoinUint16(cpu.pullStackNew16())
 	cpu.nFlag = cpu.getDRegister()&0x80 != 0
 	cpu.zFlag = cpu.getDRegister() == 0
@@ -3163,6 +3260,8 @@ func (cpu *CPU) plp() {
 }
 
 func (cpu *CPU) op28() {
+	cpu.idleAfterOpcode()
+	cpu.idleAfterOpcode()
 	cpu.plp()
 	cpu.PC++
 	cpu.step(4)
@@ -3200,6 +3299,8 @@ func (cpu *CPU) plx() {
 }
 
 func (cpu *CPU) opFA() {
+	cpu.idleAfterOpcode()
+	cpu.idleAfterOpcode()
 	cpu.plx()
 	cpu.PC++
 	cpu.step(5 - bit.BoolToUint16(cpu.xFlag))
@@ -3237,6 +3338,8 @@ func (cpu *CPU) ply() {
 }
 
 func (cpu *CPU) op7A() {
+	cpu.idleAfterOpcode()
+	cpu.idleAfterOpcode()
 	cpu.ply()
 	cpu.PC++
 	cpu.step(5 - bit.BoolToUint16(cpu.xFlag))
@@ -3257,10 +3360,11 @@ func (cpu *CPU) trb8(data uint8) uint8 {
 
 // trb test the bits of the data with the bits of the accumulator then reset the bits of the data that are ones in the accumulator handling the 8/16 case
 func (cpu *CPU) trb(laddr, haddr uint32) {
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
-		cpu.memory.SetByte(cpu.trb8(cpu.memory.GetByte(laddr)), laddr)
+		cpu.memory.SetByte(cpu.trb8(dataLo), laddr)
 	} else {
-		result := cpu.trb16(bit.JoinUint16(cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)))
+		result := cpu.trb16(bit.JoinUint16(dataLo, dataHi))
 		resultLo, resultHi := bit.SplitUint16(result)
 		cpu.memory.SetByte(resultLo, laddr)
 		cpu.memory.SetByte(resultHi, haddr)
@@ -3271,7 +3375,7 @@ func (cpu *CPU) op14() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.trb(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 func (cpu *CPU) op1C() {
 	laddr, haddr := cpu.admAbsoluteP()
@@ -3295,10 +3399,11 @@ func (cpu *CPU) tsb8(data uint8) uint8 {
 
 // tsb test the bits of the data with the bits of the accumulator then set the bits of the data that are ones in the accumulator handling the 8/16 case
 func (cpu *CPU) tsb(laddr, haddr uint32) {
+	dataLo, dataHi := cpu.readModifyData(laddr, haddr)
 	if cpu.mFlag {
-		cpu.memory.SetByte(cpu.tsb8(cpu.memory.GetByte(laddr)), laddr)
+		cpu.memory.SetByte(cpu.tsb8(dataLo), laddr)
 	} else {
-		result := cpu.tsb16(bit.JoinUint16(cpu.memory.GetByte(laddr), cpu.memory.GetByte(haddr)))
+		result := cpu.tsb16(bit.JoinUint16(dataLo, dataHi))
 		resultLo, resultHi := bit.SplitUint16(result)
 		cpu.memory.SetByte(resultLo, laddr)
 		cpu.memory.SetByte(resultHi, haddr)
@@ -3309,7 +3414,7 @@ func (cpu *CPU) op04() {
 	laddr, haddr := cpu.admDirectP()
 	cpu.tsb(laddr, haddr)
 	cpu.PC += 2
-	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() == 0))
+	cpu.step(7 - 2*bit.BoolToUint16(cpu.mFlag) + bit.BoolToUint16(cpu.getDLRegister() != 0))
 }
 
 func (cpu *CPU) op0C() {
@@ -3536,6 +3641,8 @@ const (
 )
 
 func (cpu *CPU) brk() {
+	// the signature byte is read but ignored
+	cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
 	laddr, haddr := bit.SplitUint16(cpu.getPCRegister() + 2)
 	if cpu.eFlag {
 		cpu.pushStack(haddr)
@@ -3566,6 +3673,8 @@ func (cpu *CPU) op00() {
 }
 
 func (cpu *CPU) cop() {
+	// the signature byte is read but ignored
+	cpu.memory.GetByteBank(cpu.getKRegister(), cpu.getPCRegister()+1)
 	laddr, haddr := bit.SplitUint16(cpu.getPCRegister() + 2)
 	if cpu.eFlag {
 		cpu.pushStack(haddr)
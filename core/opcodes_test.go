@@ -1,7 +1,15 @@
 package core
 
 import (
+	"math/rand"
+	"strconv"
 	"testing"
+
+	"github.com/snes-emu/gose/bit"
+	"github.com/snes-emu/gose/disasm"
+	"github.com/snes-emu/gose/io"
+	"github.com/snes-emu/gose/rom"
+	"github.com/stretchr/testify/assert"
 )
 
 func newTestMemory() *Memory {
@@ -653,17 +661,19 @@ func TestTdc(t *testing.T) {
 }
 
 func TestBrk(t *testing.T) {
+	// the BRK vector is in the ROM
+	data := make([]byte, 0x8000)
+	data[0x7fe6] = 0xab
+	data[0x7fe7] = 0xcd
+	r := rom.ROM{Data: data, Type: rom.LoROM}
+
 	mem := newTestMemory()
-	mem.initMmap()
-	mem.SetByteBank(0xab, 0x00, 0xffe6)
-	mem.SetByteBank(0xcd, 0x00, 0xffe7)
+	mem.LoadROM(r)
 
 	mem2 := newTestMemory()
-	mem2.initMmap()
-	mem2.SetByteBank(0xab, 0x00, 0xffe6)
-	mem2.SetByteBank(0xcd, 0x00, 0xffe7)
+	mem2.LoadROM(r)
 	mem2.SetByteBank(0x12, 0x00, 0x01ff)
-	mem2.SetByteBank(0x34, 0x00, 0x01fe)
+	mem2.SetByteBank(0x04, 0x00, 0x01fe)
 	mem2.SetByteBank(0x58, 0x00, 0x01fd)
 	mem2.SetByteBank(0x08, 0x00, 0x01fc)
 
@@ -672,8 +682,8 @@ func TestBrk(t *testing.T) {
 		expected CPU
 	}{
 		{
-			value:    &CPU{S: 0x01ff, PC: 0x3456, K: 0x12, dFlag: true, memory: mem},
-			expected: CPU{S: 0x01fb, iFlag: true, PC: 0x0000, memory: mem2},
+			value:    &CPU{S: 0x01ff, PC: 0x0456, K: 0x12, dFlag: true, memory: mem},
+			expected: CPU{S: 0x01fb, iFlag: true, PC: 0xcdab, memory: mem2},
 		},
 	}
 
@@ -687,3 +697,252 @@ func TestBrk(t *testing.T) {
 		}
 	}
 }
+
+func TestStackEmulation(t *testing.T) {
+	cpu := newTestCPU()
+	cpu.setEFlag(true)
+	cpu.D = 0x1234
+
+	// The 6502 instructions wrap inside page 1 after each byte
+	cpu.S = 0x0100
+	cpu.jsr(0x8000)
+	assert.Equal(t, uint16(0x01FE), cpu.S)
+	assert.Equal(t, uint8(0x00), cpu.memory.GetByteBank(0x00, 0x0100))
+	assert.Equal(t, uint8(0x02), cpu.memory.GetByteBank(0x00, 0x01FF))
+	cpu.rts()
+	assert.Equal(t, uint16(0x0100), cpu.S)
+	assert.Equal(t, uint16(0x0003), cpu.PC)
+
+	cpu.K = 0x7E
+	cpu.op4B() // PHK
+	assert.Equal(t, uint16(0x01FF), cpu.S)
+	assert.Equal(t, uint8(0x7E), cpu.memory.GetByteBank(0x00, 0x0100))
+
+	// The 65C816 instructions use the 16-bit stack pointer and move it back to page 1 afterwards
+	cpu.S = 0x0100
+	cpu.op0B() // PHD
+	assert.Equal(t, uint16(0x01FE), cpu.S)
+	assert.Equal(t, uint8(0x12), cpu.memory.GetByteBank(0x00, 0x0100))
+	assert.Equal(t, uint8(0x34), cpu.memory.GetByteBank(0x00, 0x00FF))
+
+	cpu.memory.SetByteBank(0x78, 0x00, 0x0200)
+	cpu.memory.SetByteBank(0x56, 0x00, 0x0201)
+	cpu.S = 0x01FF
+	cpu.op2B() // PLD
+	assert.Equal(t, uint16(0x0101), cpu.S)
+	assert.Equal(t, uint16(0x5678), cpu.D)
+
+	// The stack is not confined to page 1 in native mode
+	cpu.setEFlag(false)
+	cpu.S = 0x0100
+	cpu.op4B() // PHK
+	assert.Equal(t, uint16(0x00FF), cpu.S)
+}
+
+// referenceDecimal8 is the decimal mode ADC and SBC of the 65C816 in 8-bit mode, written digit by digit like in bsnes
+// The data must already be complemented for a subtraction
+func referenceDecimal8(a, data int, carry, subtract bool) (uint8, bool, bool) {
+	c := 0
+	if carry {
+		c = 1
+	}
+
+	result := a&0x0F + data&0x0F + c
+	if subtract && result <= 0x0F {
+		result -= 0x06
+	} else if !subtract && result > 0x09 {
+		result += 0x06
+	}
+	c = 0
+	if result > 0x0F {
+		c = 1
+	}
+
+	result = a&0xF0 + data&0xF0 + c<<4 + result&0x0F
+	v := ^(a^data)&(a^result)&0x80 != 0
+	if subtract && result <= 0xFF {
+		result -= 0x60
+	} else if !subtract && result > 0x9F {
+		result += 0x60
+	}
+	return uint8(result), result > 0xFF, v
+}
+
+// referenceDecimal16 is the decimal mode ADC and SBC of the 65C816 in 16-bit mode, written digit by digit like in bsnes
+// The data must already be complemented for a subtraction
+func referenceDecimal16(a, data int, carry, subtract bool) (uint16, bool, bool) {
+	c := 0
+	if carry {
+		c = 1
+	}
+
+	var result, v int
+	for _, digit := range []struct{ mask, max, adjust int }{
+		{0x000F, 0x0009, 0x0006},
+		{0x00F0, 0x009F, 0x0060},
+		{0x0F00, 0x09FF, 0x0600},
+		{0xF000, 0x9FFF, 0x6000},
+	} {
+		below := digit.mask&-digit.mask - 1
+		result = a&digit.mask + data&digit.mask + c*(below+1) + result&below
+		v = ^(a ^ data) & (a ^ result) & 0x8000
+		if subtract && result <= digit.mask|below {
+			result -= digit.adjust
+		} else if !subtract && result > digit.max {
+			result += digit.adjust
+		}
+		c = 0
+		if result > digit.mask|below {
+			c = 1
+		}
+	}
+	return uint16(result), c == 1, v != 0
+}
+
+// bcd returns the value of a BCD number, the second value is false if one of its digits is not a decimal digit
+func bcd(n int) (int, bool) {
+	res := 0
+	for mul := 1; n > 0; mul *= 10 {
+		if n&0xF > 9 {
+			return 0, false
+		}
+		res += n & 0xF * mul
+		n >>= 4
+	}
+	return res, true
+}
+
+func TestDecimal8(t *testing.T) {
+	cpu := &CPU{mFlag: true, dFlag: true}
+	failures := 0
+	for a := 0; a < 0x100; a++ {
+		for data := 0; data < 0x100; data++ {
+			for _, carry := range []bool{false, true} {
+				cpu.C, cpu.cFlag = uint16(a), carry
+				cpu.adc(uint8(data), 0)
+				result, c, v := referenceDecimal8(a, data, carry, false)
+				ok := cpu.C == uint16(result) && cpu.cFlag == c && cpu.vFlag == v && cpu.zFlag == (result == 0) && cpu.nFlag == (result&0x80 != 0)
+
+				// valid BCD numbers give the decimal result
+				if da, okA := bcd(a); okA {
+					if dd, okD := bcd(data); okD {
+						sum := da + dd + int(bit.BoolToUint8(carry))
+						expected, _ := strconv.ParseUint(strconv.Itoa(sum%100), 16, 8)
+						ok = ok && uint8(expected) == result && c == (sum >= 100)
+					}
+				}
+
+				cpu.C, cpu.cFlag = uint16(a), carry
+				cpu.sbc(uint8(data), 0)
+				result, c, v = referenceDecimal8(a, data^0xFF, carry, true)
+				ok = ok && cpu.C == uint16(result) && cpu.cFlag == c && cpu.vFlag == v && cpu.zFlag == (result == 0) && cpu.nFlag == (result&0x80 != 0)
+
+				if da, okA := bcd(a); okA {
+					if dd, okD := bcd(data); okD {
+						diff := da - dd - 1 + int(bit.BoolToUint8(carry))
+						expected, _ := strconv.ParseUint(strconv.Itoa((diff+100)%100), 16, 8)
+						ok = ok && uint8(expected) == result && c == (diff >= 0)
+					}
+				}
+
+				if !ok && failures < 10 {
+					t.Errorf("A=%02X data=%02X carry=%v", a, data, carry)
+					failures++
+				}
+			}
+		}
+	}
+}
+
+func TestDecimal16(t *testing.T) {
+	cpu := &CPU{dFlag: true}
+	r := rand.New(rand.NewSource(1))
+	failures := 0
+	for i := 0; i < 0x10000; i++ {
+		a, data, carry := r.Intn(0x10000), r.Intn(0x10000), r.Intn(2) == 1
+		// the low bytes of the operands go through every combination
+		a, data = a&0xFF00|i&0xFF, data&0xFF00|i>>8
+
+		cpu.C, cpu.cFlag = uint16(a), carry
+		cpu.adc(bit.SplitUint16(uint16(data)))
+		result, c, v := referenceDecimal16(a, data, carry, false)
+		ok := cpu.C == result && cpu.cFlag == c && cpu.vFlag == v && cpu.zFlag == (result == 0) && cpu.nFlag == (result&0x8000 != 0)
+
+		cpu.C, cpu.cFlag = uint16(a), carry
+		cpu.sbc(bit.SplitUint16(uint16(data)))
+		result, c, v = referenceDecimal16(a, data^0xFFFF, carry, true)
+		ok = ok && cpu.C == result && cpu.cFlag == c && cpu.vFlag == v && cpu.zFlag == (result == 0) && cpu.nFlag == (result&0x8000 != 0)
+
+		if !ok && failures < 10 {
+			t.Errorf("C=%04X data=%04X carry=%v", a, data, carry)
+			failures++
+		}
+	}
+
+	// valid BCD numbers give the decimal result
+	for _, tc := range []struct {
+		a, data    uint16
+		carry, sub bool
+		result     uint16
+		carryOut   bool
+		overflow   bool
+	}{
+		{0x1234, 0x4321, false, false, 0x5555, false, false},
+		{0x9999, 0x0001, false, false, 0x0000, true, false},
+		{0x5000, 0x5000, true, false, 0x0001, true, true},
+		{0x0000, 0x0001, true, true, 0x9999, false, false},
+		{0x1000, 0x0001, true, true, 0x0999, true, false},
+		{0x8000, 0x0001, false, true, 0x7998, true, true},
+	} {
+		cpu.C, cpu.cFlag = tc.a, tc.carry
+		if tc.sub {
+			cpu.sbc(bit.SplitUint16(tc.data))
+		} else {
+			cpu.adc(bit.SplitUint16(tc.data))
+		}
+		assert.Equal(t, tc.result, cpu.C, "%+v", tc)
+		assert.Equal(t, tc.carryOut, cpu.cFlag, "%+v", tc)
+		assert.Equal(t, tc.overflow, cpu.vFlag, "%+v", tc)
+	}
+}
+
+func TestOpcodeSizes(t *testing.T) {
+	// These instructions set the program counter to their target instead of skipping their operand
+	jumps := map[string]bool{
+		"BRK": true, "COP": true, "JMP": true, "JML": true, "JSR": true, "JSL": true,
+		"RTI": true, "RTS": true, "RTL": true,
+	}
+
+	mem := newFlatMemory()
+	for _, mode := range []struct {
+		name            string
+		e, mFlag, xFlag bool
+	}{
+		{"emulation", true, true, true},
+		{"native 8-bit", false, true, true},
+		{"native 16-bit", false, false, false},
+		{"native 16-bit A", false, false, true},
+	} {
+		for opcode := 0; opcode < 0x100; opcode++ {
+			op := disasm.Opcodes[opcode]
+			if jumps[op.Mnemonic] || op.Mode == disasm.Relative || op.Mode == disasm.RelativeLong {
+				continue
+			}
+
+			cpu := newCPU(mem, io.NewRegisterFactory())
+			cpu.setEFlag(mode.e)
+			cpu.mFlag, cpu.xFlag = mode.mFlag, mode.xFlag
+			cpu.K, cpu.PC, cpu.S = 0x12, 0x8000, 0x01FF
+			// block moves are repeated until the accumulator underflows
+			cpu.C = 0x0000
+			mem.SetByteBank(uint8(opcode), 0x12, 0x8000)
+			for i := uint16(1); i < 4; i++ {
+				mem.SetByteBank(0x00, 0x12, 0x8000+i)
+			}
+
+			size := cpu.disassemble().Size()
+			cpu.execOpcode()
+			assert.EqualValues(t, 0x8000+size, cpu.PC, "%s: opcode %02X (%s)", mode.name, opcode, op.Mnemonic)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package core
+
+import "time"
+
+// maxLag is the delay after which the pacer stops trying to catch up (when the emulation was paused for instance)
+const maxLag = 100 * time.Millisecond
+
+// pacer keeps the emulation running at the frame rate of the console
+type pacer struct {
+	frameDuration time.Duration // duration of a frame
+	next          time.Time     // time at which the next frame should start
+}
+
+// newPacer returns a pacer for frames lasting frameCycles master cycles at the given clock rate
+func newPacer(frameCycles uint32, masterClock uint32) *pacer {
+	p := &pacer{}
+	p.setFrameDuration(frameCycles, masterClock)
+	return p
+}
+
+// setFrameDuration sets the duration of a frame lasting frameCycles master cycles at the given clock rate
+func (p *pacer) setFrameDuration(frameCycles uint32, masterClock uint32) {
+	p.frameDuration = time.Duration(uint64(frameCycles) * uint64(time.Second) / uint64(masterClock))
+}
+
+// wait blocks until the next frame should start
+func (p *pacer) wait() {
+	now := time.Now()
+	if p.next.Before(now.Add(-maxLag)) {
+		p.next = now
+	}
+
+	time.Sleep(p.next.Sub(now))
+	p.next = p.next.Add(p.frameDuration)
+}
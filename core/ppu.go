@@ -8,14 +8,24 @@ import (
 const (
 	// HMax represents max H counter value
 	HMax = 339
-	// VBSNTSC represents VBlank start counter value in NTSC
-	VBSNTSC = 224
-	// VBSPAL represents VBlank start counter value in PAL
-	VBSPAL = 239
+	// VBS represents the last displayed line (VBlank starts on the next one)
+	VBS = 224
+	// VBSOverscan represents the last displayed line in overscan mode
+	VBSOverscan = 239
 	// VMaxNTSC represents max V counter value in NTSC
 	VMaxNTSC = 261
 	// VMaxPAL represents max V counter value in PAL
 	VMaxPAL = 311
+	// MasterClockNTSC represents the master clock rate in Hz of NTSC consoles
+	MasterClockNTSC = 21477272
+	// MasterClockPAL represents the master clock rate in Hz of PAL consoles
+	MasterClockPAL = 21281370
+	// CyclesPerLine represents the number of master cycles in a line
+	CyclesPerLine = 1364
+	// ShortLineCycles represents the number of master cycles in the short line of NTSC non-interlace odd frames
+	ShortLineCycles = 1360
+	// LongLineCycles represents the number of master cycles in the long line of PAL interlace odd frames
+	LongLineCycles = 1368
 )
 
 // PPU represents the Picture Processing Unit of the SNES
@@ -31,20 +41,27 @@ type PPU struct {
 	status         *status            // store ppu status
 	Registers      [0x40]*io.Register // Registers represents the ppu registers as methods
 
-	hCounter uint16
 	vCounter uint16
+	frame    uint64 // number of frames started since power on
+
+	ppu1OpenBus uint8 // last value read from the PPU1 registers, read back from its unused bits and some of its write-only registers
+	ppu2OpenBus uint8 // last value read from the PPU2 registers, read back from its unused bits
 
 	cpu      *CPU
 	renderer render.Renderer
 	screen   *render.Screen
+	pacer    *pacer // keeps the emulation running at the console frame rate, nil to run as fast as possible
+	latched  *PPU   // state of the PPU at the start of the current line, used to render it (nil outside of displayed lines)
+
+	dotRenderer bool     // render the lines one dot at a time instead of once they are over
+	dotLine     *dotLine // line being rendered by the dot renderer (nil outside of displayed lines)
 }
 
-// New initializes a PPU struct and returns it
-func newPPU(renderer render.Renderer, rf *io.RegisterFactory) *PPU {
+// New initializes a PPU struct and returns it, the lines are rendered one dot at a time with dotRenderer
+func newPPU(renderer render.Renderer, rf *io.RegisterFactory, dotRenderer bool) *PPU {
 	ppu := &PPU{}
 	ppu.renderer = renderer
-	// TODO: fix dimensions
-	ppu.screen = render.NewScreen(WIDTH, HEIGHT)
+	ppu.screen = render.NewScreen(ScreenWidth(), ScreenHeight())
 	ppu.vram = &vram{}
 	ppu.oam = &oam{}
 	ppu.cgram = &cgram{}
@@ -54,22 +71,24 @@ func newPPU(renderer render.Renderer, rf *io.RegisterFactory) *PPU {
 	}
 	ppu.colorMath = &colorMath{}
 	ppu.m7 = &m7{}
-	ppu.display = &display{}
+	// The screen is forced blank on power up
+	ppu.display = &display{forceBlank: true}
 	ppu.window[0] = &window{}
 	ppu.window[1] = &window{}
 	ppu.status = &status{}
 
+	// Reading a write-only register returns the CPU open bus, except for the ones returning the PPU1 open bus
 	ppu.Registers[0x00] = rf.NewRegister(nil, ppu.inidisp, "INIDISP")
 	ppu.Registers[0x01] = rf.NewRegister(nil, ppu.obsel, "OBSEL")
 	ppu.Registers[0x02] = rf.NewRegister(nil, ppu.oamaddl, "OAMADDL")
 	ppu.Registers[0x03] = rf.NewRegister(nil, ppu.oamaddh, "OAMADDH")
-	ppu.Registers[0x04] = rf.NewRegister(nil, ppu.oamdata, "OAMDATA")
-	ppu.Registers[0x05] = rf.NewRegister(nil, ppu.bgmode, "BGMODE")
-	ppu.Registers[0x06] = rf.NewRegister(nil, ppu.mosaic, "MOSAIC")
+	ppu.Registers[0x04] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.oamdata, "OAMDATA")
+	ppu.Registers[0x05] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.bgmode, "BGMODE")
+	ppu.Registers[0x06] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.mosaic, "MOSAIC")
 	ppu.Registers[0x07] = rf.NewRegister(nil, ppu.bg1sc, "BG1SC")
-	ppu.Registers[0x08] = rf.NewRegister(nil, ppu.bg2sc, "BG2SC")
-	ppu.Registers[0x09] = rf.NewRegister(nil, ppu.bg3sc, "BG3SC")
-	ppu.Registers[0x0A] = rf.NewRegister(nil, ppu.bg4sc, "BG4SC")
+	ppu.Registers[0x08] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.bg2sc, "BG2SC")
+	ppu.Registers[0x09] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.bg3sc, "BG3SC")
+	ppu.Registers[0x0A] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.bg4sc, "BG4SC")
 	ppu.Registers[0x0B] = rf.NewRegister(nil, ppu.bg12nba, "BG12NBA")
 	ppu.Registers[0x0C] = rf.NewRegister(nil, ppu.bg34nba, "BG34NBA")
 	ppu.Registers[0x0D] = rf.NewRegister(nil, ppu.bg1hofs, "BG1HOFS")
@@ -79,13 +98,13 @@ func newPPU(renderer render.Renderer, rf *io.RegisterFactory) *PPU {
 	ppu.Registers[0x11] = rf.NewRegister(nil, ppu.bg3hofs, "BG3HOFS")
 	ppu.Registers[0x12] = rf.NewRegister(nil, ppu.bg3vofs, "BG3VOFS")
 	ppu.Registers[0x13] = rf.NewRegister(nil, ppu.bg4hofs, "BG4HOFS")
-	ppu.Registers[0x14] = rf.NewRegister(nil, ppu.bg4vofs, "BG4VOFS")
-	ppu.Registers[0x15] = rf.NewRegister(nil, ppu.vmain, "VMAIN")
-	ppu.Registers[0x16] = rf.NewRegister(nil, ppu.vmaddl, "VMADDL")
+	ppu.Registers[0x14] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.bg4vofs, "BG4VOFS")
+	ppu.Registers[0x15] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.vmain, "VMAIN")
+	ppu.Registers[0x16] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.vmaddl, "VMADDL")
 	ppu.Registers[0x17] = rf.NewRegister(nil, ppu.vmaddh, "VMADDH")
-	ppu.Registers[0x18] = rf.NewRegister(nil, ppu.vmdatal, "VMDATAL")
-	ppu.Registers[0x19] = rf.NewRegister(nil, ppu.vmdatah, "VMDATAH")
-	ppu.Registers[0x1A] = rf.NewRegister(nil, ppu.m7sel, "M7SEL")
+	ppu.Registers[0x18] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.vmdatal, "VMDATAL")
+	ppu.Registers[0x19] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.vmdatah, "VMDATAH")
+	ppu.Registers[0x1A] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.m7sel, "M7SEL")
 	ppu.Registers[0x1B] = rf.NewRegister(nil, ppu.m7a, "M7A")
 	ppu.Registers[0x1C] = rf.NewRegister(nil, ppu.m7b, "M7B")
 	ppu.Registers[0x1D] = rf.NewRegister(nil, ppu.m7c, "M7C")
@@ -95,13 +114,13 @@ func newPPU(renderer render.Renderer, rf *io.RegisterFactory) *PPU {
 	ppu.Registers[0x21] = rf.NewRegister(nil, ppu.cgadd, "CGADD")
 	ppu.Registers[0x22] = rf.NewRegister(nil, ppu.cgdata, "CGDATA")
 	ppu.Registers[0x23] = rf.NewRegister(nil, ppu.w12sel, "W12SEL")
-	ppu.Registers[0x24] = rf.NewRegister(nil, ppu.w34sel, "W34SEL")
-	ppu.Registers[0x25] = rf.NewRegister(nil, ppu.wobjsel, "WOBJSEL")
-	ppu.Registers[0x26] = rf.NewRegister(nil, ppu.wh0, "WH0")
+	ppu.Registers[0x24] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.w34sel, "W34SEL")
+	ppu.Registers[0x25] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.wobjsel, "WOBJSEL")
+	ppu.Registers[0x26] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.wh0, "WH0")
 	ppu.Registers[0x27] = rf.NewRegister(nil, ppu.wh1, "WH1")
-	ppu.Registers[0x28] = rf.NewRegister(nil, ppu.wh2, "WH2")
-	ppu.Registers[0x29] = rf.NewRegister(nil, ppu.wh3, "WH3")
-	ppu.Registers[0x2A] = rf.NewRegister(nil, ppu.wbglog, "WBGLOG")
+	ppu.Registers[0x28] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.wh2, "WH2")
+	ppu.Registers[0x29] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.wh3, "WH3")
+	ppu.Registers[0x2A] = rf.NewRegister(ppu.readPPU1OpenBus, ppu.wbglog, "WBGLOG")
 	ppu.Registers[0x2B] = rf.NewRegister(nil, ppu.wobjlog, "WOBJLOG")
 	ppu.Registers[0x2C] = rf.NewRegister(nil, ppu.tm, "TM")
 	ppu.Registers[0x2D] = rf.NewRegister(nil, ppu.ts, "TS")
@@ -123,5 +142,18 @@ func newPPU(renderer render.Renderer, rf *io.RegisterFactory) *PPU {
 	ppu.Registers[0x3D] = rf.NewRegister(ppu.opvct, nil, "OPVCT")
 	ppu.Registers[0x3E] = rf.NewRegister(ppu.stat77, nil, "STAT77")
 	ppu.Registers[0x3F] = rf.NewRegister(ppu.stat78, nil, "STAT78")
+
+	ppu.dotRenderer = dotRenderer
+	if ppu.dotRenderer {
+		// The pending pixels are output before every register write so the write only affects the next ones
+		for _, reg := range ppu.Registers[:0x34] {
+			write := reg.Write
+			reg.Write = func(data uint8) {
+				ppu.catchUp(ppu.dot())
+				write(data)
+			}
+		}
+	}
+
 	return ppu
 }
@@ -15,6 +15,8 @@ type backgroundData struct {
 	PPU2ScrollLatch uint8  // latch for background offset in PPU2
 	screenMode      uint8  // Screen mode from 0 to 7
 	mosaicSize      uint8  // Size of block in mosaic mode (0=Smallest/1x1, 0xF=Largest/16x16)
+	mosaicLine      uint16 // First line of the current vertical mosaic block
+	mosaicCounter   uint8  // Number of lines left in the current vertical mosaic block
 }
 
 // BG stores data about a background
@@ -55,6 +57,18 @@ func (ppu *PPU) mosaic(data uint8) {
 	ppu.backgroundData.mosaicSize = data >> 4
 }
 
+// updateMosaicLine updates the first line of the current vertical mosaic block, it is called before rendering every line
+// The vertical mosaic counter restarts on the first line of every frame, a new mosaic size only applies once the current block ends
+func (ppu *PPU) updateMosaicLine() {
+	bd := ppu.backgroundData
+	if ppu.vCounter == 1 || bd.mosaicCounter <= 1 {
+		bd.mosaicLine = ppu.vCounter
+		bd.mosaicCounter = bd.mosaicSize + 1
+		return
+	}
+	bd.mosaicCounter--
+}
+
 // 2107h -  210Ah - BG?SC - BG? Screen Base and Screen Size (W)
 // 7-2  SC Base Address in VRAM (in 1K-word steps, aka 2K-byte steps)
 // 1-0  SC Size (0=One-Screen, 1=V-Mirror, 2=H-Mirror, 3=Four-Screen)
@@ -156,32 +170,34 @@ func (ppu *PPU) bg4vofs(data uint8) {
 	ppu.bgnvofs(4, data)
 }
 
+// scrollMask limits the background coordinates to 10 bits, backgrounds wrap around after 1024 pixels
+const scrollMask = 0x3FF
+
 // tileMapAddress returns the byte address in the VRAM of the tile we are looking for in the tilemap
+// x and y are given in tiles, a tilemap is made of 1 to 4 screens of 32x32 tiles depending on the screen size:
+// 0: SC0 SC0    1: SC0 SC1  2: SC0 SC0  3: SC0 SC1
+//    SC0 SC0       SC0 SC1     SC1 SC1     SC2 SC3
 // See here: https://wiki.superfamicom.org/backgrounds
 func (bg *bg) tileMapAddress(x uint16, y uint16) uint16 {
-	// TODO: verify that, not sure at all about this
-
-	//in case of wrapping x and y can go beyond 64
-	x = x % 64
-	y = y % 64
-	var mapIndex uint16
-	if bg.screenSize&0x1 != 0 {
-		mapIndex += x / 32
+	var screen uint16
+	if bg.screenSize&0x1 != 0 && x&0x20 != 0 {
+		screen++
 	}
-	if bg.screenSize&0x2 != 0 {
-		mapIndex += y / 32
+	if bg.screenSize&0x2 != 0 && y&0x20 != 0 {
+		// with 4 screens the bottom screens come after the 2 top ones
+		screen += uint16(bg.screenSize&0x1) + 1
 	}
 
-	base := uint16(bg.tileMapBaseAddr)
+	// Each screen is 1K words (2K bytes) long, the address wraps around the VRAM
+	base := uint16(bg.tileMapBaseAddr) + screen
 
-	return (base+mapIndex)<<11 +
+	return base<<11 +
 		((y % 32) << 6) + //a row of 32 tile is 64 = 1<<6 bytes
 		((x % 32) << 1) //a tile is 2 = 1<<1 bytes
 }
 
 func (ppu *PPU) tileFromBackground(background uint8, x uint16, y uint16) bgTile {
 	bg := ppu.backgroundData.bg[background]
-	addr := bg.tileMapAddress(x, y)
 	// raw contains:
 	// vhopppcc cccccccc
 	// v/h        = Vertical/Horizontal flip this tile.
@@ -189,17 +205,25 @@ func (ppu *PPU) tileFromBackground(background uint8, x uint16, y uint16) bgTile
 	// 	ppp        = Tile palette. The number of entries in the palette depends on the Mode and the BG.
 	// 	cccccccccc = Tile number.
 	// See: https://wiki.superfamicom.org/backgrounds
-	raw := bit.JoinUint16(ppu.vram.bytes[addr], ppu.vram.bytes[addr+1])
+	raw := ppu.tileMapEntry(background, x, y)
 
-	hSize, vSize := bg.tileSize()
+	hSize, vSize := ppu.tileSize(background)
 	colorDepth := ppu.colorDepth(background)
 	tileNumber := raw & 0x3FF
 
+	// In direct color mode, the palette bits of 256-color tiles are used as extra color bits
+	palette := uint8((raw >> 10) & 0x7)
+	directColor := colorDepth == 8 && ppu.colorMath.directColor
+	if !directColor {
+		palette = ppu.paletteBase(background, palette, colorDepth)
+	}
+
 	return bgTile{
 		baseTile: baseTile{
-			palette:    uint8((raw >> 10) & 0x7),
-			addr:       uint16(bg.tileSetBaseAddr)<<13 + uint16(tileNumber)*baseTileSize(colorDepth),
-			colorDepth: ppu.colorDepth(background),
+			palette:     palette,
+			addr:        uint16(bg.tileSetBaseAddr)<<13 + uint16(tileNumber)*baseTileSize(colorDepth),
+			colorDepth:  colorDepth,
+			directColor: directColor,
 		},
 		vFlip:    raw&0x8000 != 0,
 		hFlip:    raw&0x4000 != 0,
@@ -209,6 +233,75 @@ func (ppu *PPU) tileFromBackground(background uint8, x uint16, y uint16) bgTile
 	}
 }
 
+// tileMapEntry returns the raw tilemap entry of the tile at the given tile coordinates in the background
+func (ppu *PPU) tileMapEntry(background uint8, x uint16, y uint16) uint16 {
+	addr := ppu.backgroundData.bg[background].tileMapAddress(x, y)
+	return bit.JoinUint16(ppu.vram.bytes[addr], ppu.vram.bytes[addr+1])
+}
+
+// offsetPerTile returns true if the BG3 tilemap is used to change the scroll values of BG1 and BG2 for every column (modes 2, 4 and 6)
+func (ppu *PPU) offsetPerTile() bool {
+	mode := ppu.backgroundData.screenMode
+	return mode == 2 || mode == 4 || mode == 6
+}
+
+// columnScroll returns the horizontal and vertical scroll values of a background for the given column
+// In offset per tile modes, the entries of the first 2 rows of the scrolled BG3 tilemap contain the horizontal and vertical offsets of each column:
+// - bit 13 (resp. 14) has to be set for the offset to apply to BG1 (resp. BG2)
+// - only the 7 upper bits of the 10-bit horizontal offset replace the horizontal scroll, the fine scroll is kept
+// - in mode 4 there is a single row and bit 15 tells if the entry is a horizontal (0) or a vertical (1) offset
+// The first column is never affected.
+// See: https://wiki.superfamicom.org/offset-per-tile
+func (ppu *PPU) columnScroll(background uint8, column uint16) (uint16, uint16) {
+	bg := ppu.backgroundData.bg[background]
+	hScroll, vScroll := bg.horizontalScroll, bg.verticalScroll
+	if !ppu.offsetPerTile() || background > 1 || column == 0 {
+		return hScroll, vScroll
+	}
+
+	bg3 := ppu.backgroundData.bg[2]
+	hTileSize, vTileSize := ppu.tileSize(2)
+	x := ((column-1)*TILE_SIZE + bg3.horizontalScroll&^7) & scrollMask
+	y := bg3.verticalScroll & scrollMask
+	validBit := uint16(0x2000) << background
+
+	hOffset := ppu.tileMapEntry(2, x/hTileSize, y/vTileSize)
+	var vOffset uint16
+	if ppu.backgroundData.screenMode == 4 {
+		if hOffset&0x8000 != 0 {
+			hOffset, vOffset = 0, hOffset
+		}
+	} else {
+		vOffset = ppu.tileMapEntry(2, x/hTileSize, ((y+TILE_SIZE)&scrollMask)/vTileSize)
+	}
+
+	if hOffset&validBit != 0 {
+		hScroll = (hOffset&^7 | hScroll&7) & scrollMask
+	}
+	if vOffset&validBit != 0 {
+		vScroll = vOffset & scrollMask
+	}
+
+	return hScroll, vScroll
+}
+
+// paletteBase returns the index of the first CGRAM color of a background palette
+// 4 colors palettes use 32 colors per background in mode 0 and the first 32 colors in the other modes,
+// 16 colors palettes use the first 128 colors and 256 colors tiles use the whole CGRAM
+func (ppu *PPU) paletteBase(background uint8, palette uint8, colorDepth uint8) uint8 {
+	switch colorDepth {
+	case 2:
+		if ppu.backgroundData.screenMode == 0 {
+			return 32*background + 4*palette
+		}
+		return 4 * palette
+	case 4:
+		return 16 * palette
+	default:
+		return 0
+	}
+}
+
 //tileSize returns the size in pixel of tiles in the background
 func (bg *bg) tileSize() (uint16, uint16) {
 	hSize, vSize := uint16(8), uint16(8)
@@ -219,6 +312,36 @@ func (bg *bg) tileSize() (uint16, uint16) {
 	return hSize, vSize
 }
 
+//tileSize returns the size in pixel of tiles in the background taking the current mode into account:
+//in modes 5 and 6 tiles are always 16 pixels wide
+func (ppu *PPU) tileSize(background uint8) (uint16, uint16) {
+	hSize, vSize := ppu.backgroundData.bg[background].tileSize()
+	if ppu.hires() {
+		hSize = 16
+	}
+
+	return hSize, vSize
+}
+
+//hires returns true if the backgrounds are rendered in 512 pixels wide resolution (modes 5 and 6)
+func (ppu *PPU) hires() bool {
+	mode := ppu.backgroundData.screenMode
+	return mode == 5 || mode == 6
+}
+
+//bgInterlace returns true if the backgrounds are rendered with twice as many lines (interlace in modes 5 and 6)
+func (ppu *PPU) bgInterlace() bool {
+	return ppu.display.vScanning && ppu.hires()
+}
+
+//backgroundWidth returns the number of pixels in a background line
+func (ppu *PPU) backgroundWidth() uint16 {
+	if ppu.hires() {
+		return HIRES_WIDTH
+	}
+	return WIDTH
+}
+
 // 			1   2   3   4
 // ======---=---=---=---=
 // 0        4   4   4   4
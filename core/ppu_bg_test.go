@@ -0,0 +1,409 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/snes-emu/gose/bit"
+	"github.com/snes-emu/gose/io"
+	"github.com/snes-emu/gose/render"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTileSetAddr = 0x4000 // byte address of the tile set used by the background tests (BG12NBA = 2)
+	testTileSize    = 32     // size in bytes of a 4bpp tile
+)
+
+// setTilePixel sets the color index of a pixel of a 4bpp tile in the VRAM
+func setTilePixel(ppu *PPU, tile uint16, x, y uint16, colorIndex uint8) {
+	base := testTileSetAddr + tile*testTileSize + 2*y
+	mask := uint8(1) << (7 - x)
+	for plane, offset := range []uint16{0x00, 0x01, 0x10, 0x11} {
+		if colorIndex&(1<<uint(plane)) != 0 {
+			ppu.vram.bytes[base+offset] |= mask
+		} else {
+			ppu.vram.bytes[base+offset] &^= mask
+		}
+	}
+}
+
+// fillTile fills a 4bpp tile with a single color index
+func fillTile(ppu *PPU, tile uint16, colorIndex uint8) {
+	for y := uint16(0); y < TILE_SIZE; y++ {
+		for x := uint16(0); x < TILE_SIZE; x++ {
+			setTilePixel(ppu, tile, x, y, colorIndex)
+		}
+	}
+}
+
+// newTestBgPPU returns a PPU in mode 1 displaying BG1 with the following tiles:
+// - tile 1 has its left column using color 1, its top row using color 2 and its top left pixel using color 3
+// - tiles 2, 3, 4, 5, 17 and 18 are filled with colors 5, 6, 7, 8, 9 and 10
+// Every color in the CGRAM has its index as value so we can easily check which color is displayed
+func newTestBgPPU(dotRenderer bool) *PPU {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), dotRenderer)
+	setTestBg(ppu)
+	return ppu
+}
+
+// setTestBg sets up the PPU like newTestBgPPU
+func setTestBg(ppu *PPU) {
+	ppu.inidisp(0x0F)
+	ppu.bgmode(0x01)
+	ppu.bg12nba(testTileSetAddr >> 13)
+
+	for i := 0; i < 256; i++ {
+		ppu.cgram.bytes[2*i], ppu.cgram.bytes[2*i+1] = bit.SplitUint16(uint16(i))
+	}
+
+	for i := uint16(0); i < TILE_SIZE; i++ {
+		setTilePixel(ppu, 1, 0, i, 1)
+		setTilePixel(ppu, 1, i, 0, 2)
+	}
+	setTilePixel(ppu, 1, 0, 0, 3)
+
+	for tile, color := range map[uint16]uint8{2: 5, 3: 6, 4: 7, 5: 8, 17: 9, 18: 10} {
+		fillTile(ppu, tile, color)
+	}
+}
+
+func TestBackgroundToPixelLine(t *testing.T) {
+	const (
+		hFlip    = 0x4000
+		vFlip    = 0x8000
+		priority = 0x2000
+	)
+
+	testCases := []struct {
+		name       string
+		bgsc       uint8
+		tileSize16 bool
+		hofs, vofs uint16
+		line       uint16
+		tileMap    map[uint16]uint16 // tilemap entries indexed by VRAM byte address
+		expected   map[uint16]uint8  // expected color indexes by pixel (0 is transparent)
+	}{
+		{
+			name:     "no flip",
+			tileMap:  map[uint16]uint16{0x0000: 1},
+			expected: map[uint16]uint8{0: 3, 1: 2, 7: 2, 8: 0},
+		},
+		{
+			name:     "second row",
+			line:     1,
+			tileMap:  map[uint16]uint16{0x0000: 1},
+			expected: map[uint16]uint8{0: 1, 1: 0},
+		},
+		{
+			name:     "horizontal flip",
+			tileMap:  map[uint16]uint16{0x0000: 1 | hFlip},
+			expected: map[uint16]uint8{0: 2, 6: 2, 7: 3},
+		},
+		{
+			name:     "vertical flip",
+			line:     7,
+			tileMap:  map[uint16]uint16{0x0000: 1 | vFlip},
+			expected: map[uint16]uint8{0: 3, 1: 2},
+		},
+		{
+			name:     "scrolled tile",
+			hofs:     3,
+			vofs:     8,
+			tileMap:  map[uint16]uint16{0x0040: 2},
+			expected: map[uint16]uint8{0: 5, 4: 5, 5: 0},
+		},
+		{
+			name:     "one screen mirrors horizontally",
+			hofs:     256,
+			tileMap:  map[uint16]uint16{0x0000: 1, 0x0800: 2},
+			expected: map[uint16]uint8{0: 3},
+		},
+		{
+			name:     "64x32 second screen",
+			bgsc:     1,
+			hofs:     256,
+			tileMap:  map[uint16]uint16{0x0000: 1, 0x0800: 2},
+			expected: map[uint16]uint8{0: 5},
+		},
+		{
+			name:     "64x32 mirrors vertically",
+			bgsc:     1,
+			vofs:     256,
+			tileMap:  map[uint16]uint16{0x0000: 1, 0x0800: 2},
+			expected: map[uint16]uint8{0: 3},
+		},
+		{
+			name:     "32x64 second screen",
+			bgsc:     2,
+			vofs:     256,
+			tileMap:  map[uint16]uint16{0x0000: 1, 0x0800: 2},
+			expected: map[uint16]uint8{0: 5},
+		},
+		{
+			name:     "64x64 top right screen",
+			bgsc:     3,
+			hofs:     256,
+			tileMap:  map[uint16]uint16{0x0000: 1, 0x0800: 2, 0x1000: 3, 0x1800: 4},
+			expected: map[uint16]uint8{0: 5},
+		},
+		{
+			name:     "64x64 bottom left screen",
+			bgsc:     3,
+			vofs:     256,
+			tileMap:  map[uint16]uint16{0x0000: 1, 0x0800: 2, 0x1000: 3, 0x1800: 4},
+			expected: map[uint16]uint8{0: 6},
+		},
+		{
+			name:     "64x64 bottom right screen",
+			bgsc:     3,
+			hofs:     256,
+			vofs:     256,
+			tileMap:  map[uint16]uint16{0x0000: 1, 0x0800: 2, 0x1000: 3, 0x1800: 4},
+			expected: map[uint16]uint8{0: 7},
+		},
+		{
+			name:     "tilemap base address",
+			bgsc:     0x04 << 2,
+			tileMap:  map[uint16]uint16{0x0000: 2, 0x2000: 1},
+			expected: map[uint16]uint8{0: 3},
+		},
+		{
+			name:       "16x16 tile",
+			tileSize16: true,
+			tileMap:    map[uint16]uint16{0x0000: 1},
+			expected:   map[uint16]uint8{0: 3, 7: 2, 8: 5, 15: 5, 16: 0},
+		},
+		{
+			name:       "16x16 tile bottom half",
+			tileSize16: true,
+			line:       8,
+			tileMap:    map[uint16]uint16{0x0000: 1},
+			expected:   map[uint16]uint8{0: 9, 8: 10},
+		},
+		{
+			name:       "16x16 tile horizontal flip",
+			tileSize16: true,
+			tileMap:    map[uint16]uint16{0x0000: 1 | hFlip},
+			expected:   map[uint16]uint8{0: 5, 7: 5, 8: 2, 15: 3},
+		},
+		{
+			name:       "16x16 tile both flips",
+			tileSize16: true,
+			tileMap:    map[uint16]uint16{0x0000: 1 | hFlip | vFlip},
+			expected:   map[uint16]uint8{0: 10, 8: 9},
+		},
+		{
+			name:       "16x16 tile scroll wraps at 1024 pixels",
+			bgsc:       3,
+			tileSize16: true,
+			hofs:       1016,
+			vofs:       1024 + 8,
+			tileMap:    map[uint16]uint16{0x0000: 1},
+			expected:   map[uint16]uint8{8: 9, 16: 10},
+		},
+		{
+			name:     "priority",
+			tileMap:  map[uint16]uint16{0x0000: 2 | priority},
+			expected: map[uint16]uint8{0: 5},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ppu := newTestBgPPU(false)
+			ppu.bg1sc(tc.bgsc)
+			if tc.tileSize16 {
+				ppu.bgmode(0x11)
+			}
+
+			bg := ppu.backgroundData.bg[0]
+			bg.horizontalScroll = tc.hofs & scrollMask
+			bg.verticalScroll = tc.vofs & scrollMask
+			ppu.vCounter = tc.line
+
+			for addr, entry := range tc.tileMap {
+				ppu.vram.bytes[addr], ppu.vram.bytes[addr+1] = bit.SplitUint16(entry)
+			}
+
+			pixels := ppu.backgroundToPixelLine(0)
+			for x, colorIndex := range tc.expected {
+				pixel := pixels[x]
+				if colorIndex == 0 {
+					assert.Falsef(t, pixel.Visible, "pixel %d should be transparent", x)
+					continue
+				}
+
+				assert.Truef(t, pixel.Visible, "pixel %d should be visible", x)
+				assert.EqualValuesf(t, colorIndex, pixel.Color.Color, "pixel %d", x)
+				assert.EqualValuesf(t, bit.BoolToUint8(tc.tileMap[0]&priority != 0), pixel.Priority, "pixel %d", x)
+			}
+		})
+	}
+}
+
+func TestBackgroundPaletteBase(t *testing.T) {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+
+	ppu.bgmode(0)
+	assert.EqualValues(t, 3*32+4*5, ppu.paletteBase(3, 5, 2))
+
+	ppu.bgmode(1)
+	assert.EqualValues(t, 4*5, ppu.paletteBase(2, 5, 2))
+	assert.EqualValues(t, 16*5, ppu.paletteBase(0, 5, 4))
+
+	ppu.bgmode(3)
+	assert.EqualValues(t, 0, ppu.paletteBase(0, 5, 8))
+}
+
+func TestInterlaceBackgroundLine(t *testing.T) {
+	ppu := newTestBgPPU(false)
+	ppu.bgmode(0x05)
+	ppu.setini(0x01)
+	ppu.vram.bytes[0], ppu.vram.bytes[1] = bit.SplitUint16(1)
+
+	// Each field displays every other line of the background
+	assert.EqualValues(t, 3, ppu.backgroundToPixelLine(0)[0].Color.Color)
+	ppu.status.interlaceFrame = true
+	assert.EqualValues(t, 1, ppu.backgroundToPixelLine(0)[0].Color.Color)
+
+	// Backgrounds are not interlaced outside of modes 5 and 6
+	ppu.bgmode(0x01)
+	assert.EqualValues(t, 3, ppu.backgroundToPixelLine(0)[0].Color.Color)
+}
+
+func TestOffsetPerTile(t *testing.T) {
+	const bg3TileMapAddr = 0x1000
+
+	setEntry := func(ppu *PPU, addr uint16, entry uint16) {
+		ppu.vram.bytes[addr], ppu.vram.bytes[addr+1] = bit.SplitUint16(entry)
+	}
+
+	ppu := newTestBgPPU(false)
+	ppu.bgmode(0x02)
+	ppu.bg3sc(bg3TileMapAddr >> 9)
+	ppu.backgroundData.bg[0].horizontalScroll = 3
+
+	// BG1 tiles at (6, 0) and (2, 2)
+	setEntry(ppu, 0x000C, 2)
+	setEntry(ppu, 0x0084, 3)
+
+	// Column 1 uses a horizontal offset, column 2 a vertical offset and column 3 only applies to BG2
+	setEntry(ppu, bg3TileMapAddr, 0x2000|40)
+	setEntry(ppu, bg3TileMapAddr+0x42, 0x2000|16)
+	setEntry(ppu, bg3TileMapAddr+0x04, 0x4000|40)
+
+	hScroll, vScroll := ppu.columnScroll(0, 0)
+	assert.EqualValues(t, 3, hScroll)
+	assert.EqualValues(t, 0, vScroll)
+
+	hScroll, vScroll = ppu.columnScroll(0, 1)
+	assert.EqualValues(t, 43, hScroll)
+	assert.EqualValues(t, 0, vScroll)
+
+	hScroll, vScroll = ppu.columnScroll(0, 2)
+	assert.EqualValues(t, 3, hScroll)
+	assert.EqualValues(t, 16, vScroll)
+
+	hScroll, _ = ppu.columnScroll(0, 3)
+	assert.EqualValues(t, 3, hScroll)
+	hScroll, _ = ppu.columnScroll(1, 3)
+	assert.EqualValues(t, 40, hScroll)
+
+	// Columns are aligned on the fine horizontal scroll
+	pixels := ppu.backgroundToPixelLine(0)
+	assert.False(t, pixels[4].Visible)
+	assert.EqualValues(t, 5, pixels[5].Color.Color)
+	assert.EqualValues(t, 5, pixels[12].Color.Color)
+	assert.EqualValues(t, 6, pixels[13].Color.Color)
+	assert.EqualValues(t, 6, pixels[20].Color.Color)
+	assert.False(t, pixels[21].Visible)
+
+	// Offsets are ignored outside of modes 2, 4 and 6
+	ppu.bgmode(0x01)
+	hScroll, _ = ppu.columnScroll(0, 1)
+	assert.EqualValues(t, 3, hScroll)
+
+	// In mode 4 a single entry is used, bit 15 selects a vertical offset
+	ppu.bgmode(0x04)
+	hScroll, vScroll = ppu.columnScroll(0, 1)
+	assert.EqualValues(t, 43, hScroll)
+	assert.EqualValues(t, 0, vScroll)
+
+	setEntry(ppu, bg3TileMapAddr, 0x8000|0x2000|16)
+	hScroll, vScroll = ppu.columnScroll(0, 1)
+	assert.EqualValues(t, 3, hScroll)
+	assert.EqualValues(t, 16, vScroll)
+
+	hScroll, vScroll = ppu.columnScroll(0, 2)
+	assert.EqualValues(t, 3, hScroll)
+	assert.EqualValues(t, 0, vScroll)
+}
+
+func TestMosaic(t *testing.T) {
+	ppu := newTestBgPPU(false)
+	ppu.vram.bytes[0], ppu.vram.bytes[1] = bit.SplitUint16(1)
+
+	// 4x4 blocks on BG1
+	ppu.mosaic(0x31)
+
+	ppu.vCounter = 1
+	ppu.updateMosaicLine()
+	ppu.backgroundData.bg[0].verticalScroll = 0xFFFF & scrollMask
+	pixels := ppu.backgroundToPixelLine(0)
+	assert.EqualValues(t, 3, pixels[0].Color.Color)
+	assert.EqualValues(t, 3, pixels[3].Color.Color)
+	assert.EqualValues(t, 2, pixels[4].Color.Color)
+	assert.False(t, pixels[8].Visible)
+
+	// Lines of a block use the first line of the block
+	for ppu.vCounter = 2; ppu.vCounter <= 4; ppu.vCounter++ {
+		ppu.updateMosaicLine()
+		assert.EqualValues(t, 1, ppu.backgroundData.mosaicLine)
+		assert.EqualValues(t, 3, ppu.backgroundToPixelLine(0)[0].Color.Color)
+	}
+
+	// A new size only applies when the current block ends
+	ppu.mosaic(0x11)
+	ppu.vCounter = 5
+	ppu.updateMosaicLine()
+	assert.EqualValues(t, 5, ppu.backgroundData.mosaicLine)
+	ppu.vCounter = 6
+	ppu.updateMosaicLine()
+	assert.EqualValues(t, 5, ppu.backgroundData.mosaicLine)
+	ppu.vCounter = 7
+	ppu.updateMosaicLine()
+	assert.EqualValues(t, 7, ppu.backgroundData.mosaicLine)
+
+	// The counter restarts on the first line of the frame
+	ppu.vCounter = 1
+	ppu.updateMosaicLine()
+	assert.EqualValues(t, 1, ppu.backgroundData.mosaicLine)
+
+	// Backgrounds without mosaic are not affected
+	ppu.mosaic(0x32)
+	ppu.vCounter = 2
+	pixels = ppu.backgroundToPixelLine(0)
+	assert.EqualValues(t, 1, pixels[0].Color.Color)
+	assert.False(t, pixels[1].Visible)
+}
+
+func TestDirectColor(t *testing.T) {
+	assert.EqualValues(t, 0x1C<<10|0x1E<<5|0x1E, directColor(0xFF, 0x7).Color)
+	assert.EqualValues(t, 0x1E, directColor(0x07, 0x1).Color)
+	assert.EqualValues(t, 0x18<<10, directColor(0xC0, 0x0).Color)
+	assert.EqualValues(t, 0x04<<10|0x02<<5, directColor(0x00, 0x6).Color)
+
+	ppu := newTestBgPPU(false)
+	ppu.bgmode(0x03)
+	ppu.cgwsel(0x01)
+	ppu.vram.bytes[0], ppu.vram.bytes[1] = bit.SplitUint16(0x0400 | 0x40)
+
+	// Color index 0x09 with the palette bits 001 in the 8bpp tile 0x40
+	base := uint16(testTileSetAddr + 0x40*64)
+	ppu.vram.bytes[base] = 0x80
+	ppu.vram.bytes[base+0x11] = 0x80
+
+	pixel := ppu.backgroundToPixelLine(0)[0]
+	assert.True(t, pixel.Visible)
+	assert.EqualValues(t, 0x04<<5|0x06, pixel.Color.Color)
+}
@@ -33,7 +33,7 @@ func (ppu *PPU) cgdata(data uint8) {
 	if ppu.cgram.addr%2 == 0 {
 		// Write to the temporary variable
 		ppu.cgram.lsb = data
-	} else {
+	} else if ppu.writeAllowed("CGRAM", ppu.cgram.addr-1, ppu.cgramWritable) {
 		// addr - 1 because we increment even if we wrote in the lsb
 		ppu.cgram.write(ppu.cgram.addr-1, ppu.cgram.lsb, data)
 	}
@@ -43,7 +43,12 @@ func (ppu *PPU) cgdata(data uint8) {
 // 213B - RDCGRAM - Palette CGRAM Data Read (R)
 func (ppu *PPU) rdcgram() uint8 {
 	res := ppu.cgram.read(ppu.cgram.addr)
+	// bit 7 of the upper byte of a color is the PPU2 open bus
+	if ppu.cgram.addr&1 != 0 {
+		res = res&0x7F | ppu.ppu2OpenBus&0x80
+	}
 	ppu.cgram.incrAddr()
+	ppu.ppu2OpenBus = res
 	return res
 }
 
@@ -140,11 +145,27 @@ func (ppu *PPU) tileColor(tile baseTile, x, y uint16) render.BGR555 {
 		return render.BGR555{Transparent: true}
 	}
 
+	if tile.directColor {
+		return directColor(idx, tile.palette)
+	}
+
 	// Sprite colors are stored in the CGRAM starting at palette 8
 	colorWordAddr := 2 * uint16(tile.palette+idx)
 	return render.BGR555{
 		Color: bit.JoinUint16(ppu.cgram.bytes[colorWordAddr], ppu.cgram.bytes[colorWordAddr+1]),
-	}.ApplyBrightness(ppu.display.brightness)
+	}
+}
+
+// directColor returns the color encoded in a 256-color index (BBGGGRRR) and the palette bits (bgr) of a tile
+// The palette bits add an extra bit of precision to each component: RRRr0, GGGg0 and BBb00
+func directColor(idx uint8, palette uint8) render.BGR555 {
+	r := uint16(idx&0x07)<<2 | uint16(palette&0x1)<<1
+	g := uint16(idx&0x38)>>1 | uint16(palette&0x2)
+	b := uint16(idx&0xC0)>>3 | uint16(palette&0x4)
+
+	return render.BGR555{
+		Color: b<<10 | g<<5 | r,
+	}
 }
 
 func (ppu *PPU) backdropPixel() render.Pixel {
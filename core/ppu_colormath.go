@@ -1,5 +1,7 @@
 package core
 
+import "github.com/snes-emu/gose/render"
+
 type colorMath struct {
 	mainScreenBlack uint8 // Force main screen black (possible values: (3=Always, 2=MathWindow, 1=NotMathWin, 0=Never))
 	enable          uint8 // Global color math enable (possible values: (0=Always, 1=MathWindow, 2=NotMathWin, 3=Never))
@@ -58,3 +60,13 @@ func (ppu *PPU) coldata(data uint8) {
 		ppu.colorMath.red = intensity
 	}
 }
+
+// fixedColorPixel returns the fixed color set by COLDATA, it is used as the sub screen backdrop
+func (ppu *PPU) fixedColorPixel() render.Pixel {
+	return render.Pixel{
+		Visible: true,
+		Color: render.BGR555{
+			Color: uint16(ppu.colorMath.blue)<<10 | uint16(ppu.colorMath.green)<<5 | uint16(ppu.colorMath.red),
+		},
+	}
+}
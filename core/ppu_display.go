@@ -1,5 +1,11 @@
 package core
 
+import (
+	"github.com/snes-emu/gose/config"
+	"github.com/snes-emu/gose/log"
+	"go.uber.org/zap"
+)
+
 type display struct {
 	brightness  uint8 // Display brightness
 	forceBlank  bool  // If true, force screen to blank
@@ -11,20 +17,96 @@ type display struct {
 	ExtSynchro  bool  // usually 0, used with sfx chip
 }
 
-// VDisplay returns of the vertical size of the screen depending on the mode (NTSC/PAL)
+// VDisplay returns the last displayed line depending on the overscan mode (224 or 239 lines)
 func (ppu *PPU) VDisplay() uint16 {
 	if ppu.display.bgVDisplay {
-		return VBSPAL
+		return VBSOverscan
 	}
-	return VBSNTSC
+	return VBS
 }
 
-// VDisplayEnd returns the extra VBLANK area of the screen depending on the mode (NTSC/PAL)
+// VDisplayEnd returns the last line of the frame depending on the region (NTSC/PAL)
+// In interlace mode the fields with the interlace field flag cleared have an extra line (263 lines in NTSC, 313 in PAL)
 func (ppu *PPU) VDisplayEnd() uint16 {
-	if ppu.display.bgVDisplay {
-		return VMaxPAL
+	end := uint16(VMaxNTSC)
+	if ppu.status.palMode {
+		end = VMaxPAL
+	}
+	if ppu.display.vScanning && !ppu.status.interlaceFrame {
+		end++
+	}
+	return end
+}
+
+// setRegion sets the region of the console (NTSC or PAL), this changes the number of lines and the frame rate
+func (ppu *PPU) setRegion(pal bool) {
+	ppu.status.palMode = pal
+	if ppu.pacer != nil {
+		ppu.pacer.setFrameDuration(ppu.frameCycles(), ppu.masterClock())
+	}
+}
+
+// masterClock returns the master clock rate in Hz depending on the region
+func (ppu *PPU) masterClock() uint32 {
+	if ppu.status.palMode {
+		return MasterClockPAL
 	}
-	return VMaxNTSC
+	return MasterClockNTSC
+}
+
+// frameCycles returns the number of master cycles in a frame (in the current field in interlace mode)
+func (ppu *PPU) frameCycles() uint32 {
+	return CyclesPerLine * (uint32(ppu.VDisplayEnd()) + 1)
+}
+
+// activeDisplay returns true while the PPU is drawing the picture (from line 0 to the last displayed line unless the screen is forced blank)
+// During active display the VRAM, OAM and CGRAM are being read by the PPU and can't be accessed freely
+func (ppu *PPU) activeDisplay() bool {
+	return !ppu.display.forceBlank && ppu.vCounter <= ppu.VDisplay()
+}
+
+// dot returns the horizontal position of the PPU in the current line (the H counter)
+func (ppu *PPU) dot() uint16 {
+	return ppu.hCounterAt(ppu.cpu.cycles)
+}
+
+// hBlank returns true if the PPU is not drawing pixels on the current line
+func (ppu *PPU) hBlank() bool {
+	dot := ppu.dot()
+	return dot < 22 || dot >= 274
+}
+
+// vramWritable returns true if the VRAM can be written: only during VBlank or forced blank
+func (ppu *PPU) vramWritable() bool {
+	return !ppu.activeDisplay()
+}
+
+// oamWritable returns true if the OAM can be written: only during VBlank or forced blank
+func (ppu *PPU) oamWritable() bool {
+	return !ppu.activeDisplay()
+}
+
+// cgramWritable returns true if the CGRAM can be written: during VBlank, forced blank or HBlank
+// The other writes are dropped, the console writes them to the address read for the current pixel which is not tracked
+func (ppu *PPU) cgramWritable() bool {
+	return !ppu.activeDisplay() || ppu.vCounter == 0 || ppu.hBlank()
+}
+
+// writeAllowed returns whether a write to the VRAM, OAM or CGRAM can be performed
+// Writes are only dropped when accurate access is enabled, dropped writes are logged in debug mode
+func (ppu *PPU) writeAllowed(memory string, addr uint16, writable func() bool) bool {
+	if !config.AccurateAccess() || writable() {
+		return true
+	}
+
+	log.Debug(
+		"dropped write during active display",
+		zap.String("memory", memory),
+		zap.Uint16("addr", addr),
+		zap.Uint16("vCounter", ppu.vCounter),
+		zap.Uint16("dot", ppu.dot()),
+	)
+	return false
 }
 
 // 2100h - INIDISP - Display Control 1 (W)
@@ -38,16 +120,15 @@ func (ppu *PPU) tm(data uint8) {
 	for i := uint8(0); i < 4; i++ {
 		ppu.backgroundData.bg[i].mainScreen = data&(1<<i) != 0
 	}
-	ppu.oam.mainScreen = data&0x01 != 0
+	ppu.oam.mainScreen = data&0x10 != 0
 }
 
 // 212Dh - TS - Sub Screen Designation (W)
 func (ppu *PPU) ts(data uint8) {
 	for i := uint8(0); i < 4; i++ {
 		ppu.backgroundData.bg[i].subScreen = data&(1<<i) != 0
-		data = data >> 1
 	}
-	ppu.oam.subScreen = data&0x01 != 0
+	ppu.oam.subScreen = data&0x10 != 0
 }
 
 // 2133h - SETINI - Display Control 2 (W)
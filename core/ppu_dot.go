@@ -0,0 +1,94 @@
+package core
+
+import "github.com/snes-emu/gose/render"
+
+// firstVisibleDot is the dot at which the first pixel of a line is output
+const firstVisibleDot = 22
+
+// dotLine is the line being rendered by the dot renderer
+// Unlike the line renderer, the dot renderer outputs the pixels as the CPU runs so register writes
+// done in the middle of a line take effect on the next pixel (or on the next tile fetch for the background tiles)
+// The internal fetch pipeline of the PPU is not modeled: the background tiles are fetched when their first pixel
+// is output (the PPU reads them a few dots ahead) and the sprites are rendered at the start of the line (the PPU
+// fetches their tiles during the HBlank before it), so writes done close to a fetch can take effect a bit early
+type dotLine struct {
+	bgLines [4]*bgLine     // rows of the backgrounds, tiles are fetched as the pixels are output
+	sprites []render.Pixel // sprites are rendered at the start of the line, before its first pixel
+	pixels  []render.Pixel // output pixels, sub and main screen pixels are interleaved
+	next    uint16         // next low-res pixel to output
+}
+
+// startDotLine starts rendering the current line with the dot renderer
+func (ppu *PPU) startDotLine() {
+	l := &dotLine{
+		pixels: make([]render.Pixel, HIRES_WIDTH),
+	}
+	for _, bgIndex := range ppu.validBackgrounds() {
+		l.bgLines[bgIndex] = ppu.newBgLine(bgIndex)
+	}
+	l.sprites = ppu.spritesPixelLine()
+
+	ppu.dotLine = l
+}
+
+// catchUp outputs the pixels of the current line up to the given dot using the current register values
+func (ppu *PPU) catchUp(dot uint16) {
+	l := ppu.dotLine
+	if l == nil || dot < firstVisibleDot {
+		return
+	}
+
+	end := dot - firstVisibleDot
+	if end > WIDTH {
+		end = WIDTH
+	}
+
+	for ; l.next < end; l.next++ {
+		ppu.renderDot(l, l.next)
+	}
+}
+
+// finishDotLine outputs the remaining pixels of the current line and writes it on the screen
+func (ppu *PPU) finishDotLine() {
+	ppu.catchUp(firstVisibleDot + WIDTH)
+	ppu.setScreenLine(ppu.vCounter-1, ppu.dotLine.pixels)
+	ppu.dotLine = nil
+}
+
+// renderDot outputs the low-res pixel x of the line
+// Outside of hi-res modes the main screen pixel is output twice
+func (ppu *PPU) renderDot(l *dotLine, x uint16) {
+	var mainLayers, subLayers [layerNumber][]render.Pixel
+
+	for bgIndex, bgLine := range l.bgLines {
+		if bgLine == nil {
+			continue
+		}
+
+		ppu.renderBgLine(bgLine, (x+1)*uint16(len(bgLine.pixels)/WIDTH))
+		bg := ppu.backgroundData.bg[bgIndex]
+		if bg.mainScreen {
+			mainLayers[bgIndex] = bgLine.pixels
+		}
+		if bg.subScreen {
+			subLayers[bgIndex] = bgLine.pixels
+		}
+	}
+
+	if ppu.oam.mainScreen {
+		mainLayers[objLayer] = l.sprites
+	}
+	if ppu.oam.subScreen {
+		subLayers[objLayer] = l.sprites
+	}
+
+	priorities := ppu.layerPriorities()
+	main := screenPixel(mainLayers, priorities, ppu.backdropPixel(), int(x), 1)
+	sub := main
+	if ppu.hires() || ppu.display.hPseudoMode {
+		sub = screenPixel(subLayers, priorities, ppu.fixedColorPixel(), int(x), 0)
+	}
+
+	l.pixels[2*x] = ppu.outputPixel(sub)
+	l.pixels[2*x+1] = ppu.outputPixel(main)
+}
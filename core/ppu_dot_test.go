@@ -0,0 +1,112 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/snes-emu/gose/bit"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestScenePPU returns a PPU displaying 2 backgrounds and some sprites
+func newTestScenePPU(dotRenderer bool) *PPU {
+	ppu := newTestBgPPU(dotRenderer)
+	ppu.cpu = newTestCPU()
+
+	ppu.tm(0x13)
+	ppu.bg2sc(0x04<<2 | 0x01)
+	ppu.bg12nba(testTileSetAddr>>13 | testTileSetAddr>>9)
+	ppu.mosaic(0x22)
+	ppu.backgroundData.bg[0].horizontalScroll = 3
+	ppu.backgroundData.bg[0].verticalScroll = 5
+	ppu.backgroundData.bg[1].horizontalScroll = 100
+	ppu.backgroundData.bg[1].verticalScroll = 7
+
+	for i := uint16(0); i < 0x400; i++ {
+		bg1Entry := (i*7)%6 + 1 | (i%4)<<14 | (i%3)<<10
+		bg2Entry := (i*5)%6 | (i%2)<<13
+		ppu.vram.bytes[2*i], ppu.vram.bytes[2*i+1] = bit.SplitUint16(bg1Entry)
+		ppu.vram.bytes[0x2000+2*i], ppu.vram.bytes[0x2000+2*i+1] = bit.SplitUint16(bg2Entry)
+	}
+
+	ppu.obsel(testTileSetAddr >> 14)
+	for i := 0; i < 20; i++ {
+		setSprite(ppu, i, uint8(13*i), uint8(11*i))
+		ppu.oam.bytes[4*i+2] = uint8(i%5 + 1)
+		ppu.oam.bytes[4*i+3] = uint8(i%4) << 4
+	}
+	for i := 20; i < 128; i++ {
+		setSprite(ppu, i, 0, 0xF0)
+	}
+
+	return ppu
+}
+
+// renderTestFrame renders all the displayed lines of a frame
+func renderTestFrame(ppu *PPU) {
+	ppu.vCounter = 0
+	for ppu.vCounter <= ppu.VDisplay() {
+		ppu.renderLine()
+	}
+}
+
+func TestDotRendererMatchesLineRenderer(t *testing.T) {
+	linePPU := newTestScenePPU(false)
+	dotPPU := newTestScenePPU(true)
+
+	renderTestFrame(linePPU)
+	renderTestFrame(dotPPU)
+
+	for i := range linePPU.screen.Pixels {
+		if !assert.Equalf(t, linePPU.screen.Pixels[i], dotPPU.screen.Pixels[i], "pixel %d", i) {
+			return
+		}
+	}
+}
+
+func TestDotRendererMidLineWrites(t *testing.T) {
+	cpu := newTestConsole(true)
+	ppu := cpu.ppu
+	setTestBg(ppu)
+	ppu.tm(0x01)
+	for i := uint16(0); i < 32; i++ {
+		ppu.vram.bytes[2*i], ppu.vram.bytes[2*i+1] = bit.SplitUint16(2 + i%2)
+	}
+
+	// The program runs from the low WRAM: immediate loads take 16 master cycles
+	// and absolute stores 30 master cycles, the write being done in the last 6
+	program := []uint8{
+		0xA9, 0x07, 0x8D, 0x00, 0x21, // LDA #$07, STA $2100
+		0xA9, 0x08, 0x8D, 0x0D, 0x21, // LDA #$08, STA $210D
+		0xA9, 0x00, 0x8D, 0x0D, 0x21, // LDA #$00, STA $210D
+	}
+	for i, b := range program {
+		cpu.memory.SetByteBank(b, 0x00, 0x0100+uint16(i))
+	}
+	cpu.PC, cpu.mFlag = 0x0100, true
+
+	ppu.vCounter = 0
+	ppu.renderLine()
+	cpu.cycles = 4*(firstVisibleDot+20) - 46
+
+	// Brightness changes take effect on the next pixel
+	cpu.execOpcode()
+	cpu.execOpcode()
+	assert.EqualValues(t, firstVisibleDot+20, ppu.dot())
+
+	// Scroll changes take effect on the next tile fetch, the second write is done at pixel 43
+	for i := 0; i < 4; i++ {
+		cpu.execOpcode()
+	}
+	assert.EqualValues(t, firstVisibleDot+43, ppu.dot())
+	assert.EqualValues(t, 8, ppu.backgroundData.bg[0].horizontalScroll)
+
+	ppu.finishDotLine()
+	row := ppu.screen.Pixels[:ppu.screen.Width]
+	assert.EqualValues(t, 5, row[2*19+1].Color.Color)
+	assert.EqualValues(t, 2, row[2*20+1].Color.Color)
+	// The tile of the current column was fetched before the scroll change
+	assert.EqualValues(t, 3, row[2*43+1].Color.Color)
+	assert.EqualValues(t, 3, row[2*47+1].Color.Color)
+	assert.EqualValues(t, 3, row[2*48+1].Color.Color)
+	assert.EqualValues(t, 2, row[2*56+1].Color.Color)
+}
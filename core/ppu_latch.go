@@ -0,0 +1,44 @@
+package core
+
+// latchRegisters copies the PPU state used to render the current line, it is called at the start of every displayed line
+// The line is only rendered once it is over, so registers written while the line is being drawn
+// (from an IRQ handler for instance) only affect the next lines like on the hardware
+// VRAM content is shared with the latched state until it is written during the line (see latchVRAM)
+func (ppu *PPU) latchRegisters() {
+	latched := *ppu
+	latched.latched = nil
+
+	backgroundData := *ppu.backgroundData
+	for i := range backgroundData.bg {
+		bg := *backgroundData.bg[i]
+		backgroundData.bg[i] = &bg
+	}
+	latched.backgroundData = &backgroundData
+
+	oam := *ppu.oam
+	latched.oam = &oam
+	cgram := *ppu.cgram
+	latched.cgram = &cgram
+	colorMath := *ppu.colorMath
+	latched.colorMath = &colorMath
+	m7 := *ppu.m7
+	latched.m7 = &m7
+	display := *ppu.display
+	latched.display = &display
+	for i := range latched.window {
+		window := *ppu.window[i]
+		latched.window[i] = &window
+	}
+
+	ppu.latched = &latched
+}
+
+// latchVRAM gives the latched state its own copy of the VRAM before it is written while the line is being drawn
+// The VRAM can't be written during active display with the accurate access option, without it the writes
+// only affect the next lines like the registers
+func (ppu *PPU) latchVRAM() {
+	if ppu.latched != nil && ppu.latched.vram == ppu.vram {
+		vram := *ppu.vram
+		ppu.latched.vram = &vram
+	}
+}
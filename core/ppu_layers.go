@@ -0,0 +1,187 @@
+package core
+
+import "github.com/snes-emu/gose/render"
+
+const (
+	bg1Layer = iota
+	bg2Layer
+	bg3Layer
+	bg4Layer
+	objLayer
+	layerNumber
+)
+
+// layerPriority identifies a layer and one of its priority level
+type layerPriority struct {
+	layer    uint8
+	priority uint8
+}
+
+// Layers in front to back order for each mode, OBJ priorities go from 0 to 3 and BG priorities from 0 to 1
+// See: https://problemkaputt.de/fullsnes.htm#snesppubgpriorityorder
+var (
+	mode0Priorities = []layerPriority{
+		{objLayer, 3}, {bg1Layer, 1}, {bg2Layer, 1},
+		{objLayer, 2}, {bg1Layer, 0}, {bg2Layer, 0},
+		{objLayer, 1}, {bg3Layer, 1}, {bg4Layer, 1},
+		{objLayer, 0}, {bg3Layer, 0}, {bg4Layer, 0},
+	}
+	mode1Priorities = []layerPriority{
+		{objLayer, 3}, {bg1Layer, 1}, {bg2Layer, 1},
+		{objLayer, 2}, {bg1Layer, 0}, {bg2Layer, 0},
+		{objLayer, 1}, {bg3Layer, 1},
+		{objLayer, 0}, {bg3Layer, 0},
+	}
+	// mode 1 with the BG3 priority bit of BGMODE set
+	mode1BG3Priorities = []layerPriority{
+		{bg3Layer, 1}, {objLayer, 3}, {bg1Layer, 1}, {bg2Layer, 1},
+		{objLayer, 2}, {bg1Layer, 0}, {bg2Layer, 0},
+		{objLayer, 1},
+		{objLayer, 0}, {bg3Layer, 0},
+	}
+	// modes 2 to 5
+	mode2Priorities = []layerPriority{
+		{objLayer, 3}, {bg1Layer, 1},
+		{objLayer, 2}, {bg2Layer, 1},
+		{objLayer, 1}, {bg1Layer, 0},
+		{objLayer, 0}, {bg2Layer, 0},
+	}
+	mode6Priorities = []layerPriority{
+		{objLayer, 3}, {bg1Layer, 1},
+		{objLayer, 2},
+		{objLayer, 1}, {bg1Layer, 0},
+		{objLayer, 0},
+	}
+	mode7Priorities = []layerPriority{
+		{objLayer, 3},
+		{objLayer, 2},
+		{objLayer, 1}, {bg2Layer, 1},
+		{objLayer, 0}, {bg1Layer, 0}, {bg2Layer, 0},
+	}
+)
+
+// layerPriorities returns the layers in front to back order for the current mode
+func (ppu *PPU) layerPriorities() []layerPriority {
+	switch ppu.backgroundData.screenMode {
+	case 0:
+		return mode0Priorities
+	case 1:
+		if ppu.backgroundData.bg[2].priority {
+			return mode1BG3Priorities
+		}
+		return mode1Priorities
+	case 2, 3, 4, 5:
+		return mode2Priorities
+	case 6:
+		return mode6Priorities
+	default:
+		return mode7Priorities
+	}
+}
+
+// pixelLine renders the current line with the current master brightness so it can be changed mid-frame
+// Lines are black during forced blank
+func (ppu *PPU) pixelLine() []render.Pixel {
+	if ppu.display.forceBlank {
+		pixels := make([]render.Pixel, WIDTH)
+		for i := range pixels {
+			pixels[i] = render.Pixel{Visible: true}
+		}
+		return pixels
+	}
+
+	pixels := ppu.screensPixelLine()
+	for i := range pixels {
+		pixels[i] = ppu.outputPixel(pixels[i])
+	}
+
+	return pixels
+}
+
+// outputPixel applies the master brightness to a pixel, pixels are black during forced blank
+func (ppu *PPU) outputPixel(pixel render.Pixel) render.Pixel {
+	if ppu.display.forceBlank {
+		return render.Pixel{Visible: true}
+	}
+
+	pixel.Color = pixel.Color.ApplyBrightness(ppu.display.brightness)
+	return pixel
+}
+
+// screensPixelLine renders the main and sub screens for the current line
+// In modes 5 and 6 and in pseudo hi-res mode the line is 512 pixels wide:
+// sub screen pixels are displayed on even columns and main screen pixels on odd ones
+func (ppu *PPU) screensPixelLine() []render.Pixel {
+	var mainLayers, subLayers [layerNumber][]render.Pixel
+
+	for _, bgIndex := range ppu.validBackgrounds() {
+		bg := ppu.backgroundData.bg[bgIndex]
+		if !bg.mainScreen && !bg.subScreen {
+			continue
+		}
+
+		line := ppu.backgroundToPixelLine(bgIndex)
+		if bg.mainScreen {
+			mainLayers[bgIndex] = line
+		}
+		if bg.subScreen {
+			subLayers[bgIndex] = line
+		}
+	}
+
+	// Sprites are evaluated even when they are not displayed, the evaluation updates the ppu status
+	sprites := ppu.spritesPixelLine()
+	if ppu.oam.mainScreen {
+		mainLayers[objLayer] = sprites
+	}
+	if ppu.oam.subScreen {
+		subLayers[objLayer] = sprites
+	}
+
+	main := ppu.screenPixelLine(mainLayers, ppu.backdropPixel(), 1)
+	if !ppu.hires() && !ppu.display.hPseudoMode {
+		return main
+	}
+
+	sub := ppu.screenPixelLine(subLayers, ppu.fixedColorPixel(), 0)
+	pixels := make([]render.Pixel, HIRES_WIDTH)
+	for x := range main {
+		pixels[2*x] = sub[x]
+		pixels[2*x+1] = main[x]
+	}
+
+	return pixels
+}
+
+// screenPixelLine keeps, for every pixel of the given layers, the visible one with the highest priority (or the backdrop if none is visible)
+func (ppu *PPU) screenPixelLine(layers [layerNumber][]render.Pixel, backdrop render.Pixel, hiresOffset int) []render.Pixel {
+	pixels := make([]render.Pixel, WIDTH)
+	priorities := ppu.layerPriorities()
+	for x := range pixels {
+		pixels[x] = screenPixel(layers, priorities, backdrop, x, hiresOffset)
+	}
+
+	return pixels
+}
+
+// screenPixel returns the visible pixel with the highest priority at x in the given layers (or the backdrop if none is visible)
+// Hi-res background lines are 512 pixels wide, for those the pixel at 2*x+hiresOffset is used
+func screenPixel(layers [layerNumber][]render.Pixel, priorities []layerPriority, backdrop render.Pixel, x int, hiresOffset int) render.Pixel {
+	for _, lp := range priorities {
+		line := layers[lp.layer]
+		if line == nil {
+			continue
+		}
+
+		idx := x
+		if len(line) == HIRES_WIDTH {
+			idx = 2*x + hiresOffset
+		}
+
+		if line[idx].Visible && line[idx].Priority == lp.priority {
+			return line[idx]
+		}
+	}
+
+	return backdrop
+}
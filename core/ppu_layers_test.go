@@ -0,0 +1,74 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/snes-emu/gose/bit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHiresBackgroundLine(t *testing.T) {
+	ppu := newTestBgPPU(false)
+	ppu.bgmode(0x05)
+	ppu.vram.bytes[0], ppu.vram.bytes[1] = bit.SplitUint16(1)
+
+	// Tiles are always 16 pixels wide in mode 5: tile 1 is followed by tile 2
+	pixels := ppu.backgroundToPixelLine(0)
+	assert.Len(t, pixels, HIRES_WIDTH)
+	assert.EqualValues(t, 3, pixels[0].Color.Color)
+	assert.EqualValues(t, 2, pixels[7].Color.Color)
+	assert.EqualValues(t, 5, pixels[8].Color.Color)
+	assert.EqualValues(t, 5, pixels[15].Color.Color)
+	assert.False(t, pixels[16].Visible)
+
+	// The horizontal scroll is counted in low-res pixels
+	ppu.backgroundData.bg[0].horizontalScroll = 4
+	pixels = ppu.backgroundToPixelLine(0)
+	assert.EqualValues(t, 5, pixels[0].Color.Color)
+}
+
+func TestPseudoHiresPixelLine(t *testing.T) {
+	ppu := newTestBgPPU(false)
+	ppu.vram.bytes[0], ppu.vram.bytes[1] = bit.SplitUint16(2)
+	ppu.tm(0x01)
+	ppu.coldata(0xE0 | 0x0A)
+
+	pixels := ppu.pixelLine()
+	assert.Len(t, pixels, WIDTH)
+	assert.EqualValues(t, 5, pixels[0].Color.Color)
+
+	// Sub screen pixels are displayed on even columns and main screen ones on odd columns
+	ppu.setini(0x08)
+	pixels = ppu.pixelLine()
+	assert.Len(t, pixels, HIRES_WIDTH)
+	assert.EqualValues(t, 0x0A<<10|0x0A<<5|0x0A, pixels[0].Color.Color)
+	assert.EqualValues(t, 5, pixels[1].Color.Color)
+
+	// Layers enabled on the sub screen are displayed on even columns
+	ppu.ts(0x01)
+	pixels = ppu.pixelLine()
+	assert.EqualValues(t, 5, pixels[0].Color.Color)
+	assert.EqualValues(t, 5, pixels[1].Color.Color)
+}
+
+func TestPixelLineBrightness(t *testing.T) {
+	ppu := newTestBgPPU(false)
+	ppu.vram.bytes[0], ppu.vram.bytes[1] = bit.SplitUint16(2)
+	ppu.cgram.bytes[10], ppu.cgram.bytes[11] = bit.SplitUint16(0x7FFF)
+	ppu.tm(0x01)
+
+	assert.EqualValues(t, 0x7FFF, ppu.pixelLine()[0].Color.Color)
+
+	// Brightness is applied on the whole line
+	ppu.inidisp(0x07)
+	assert.EqualValues(t, 0x0F<<10|0x0F<<5|0x0F, ppu.pixelLine()[0].Color.Color)
+
+	// Lines are black during forced blank
+	ppu.inidisp(0x8F)
+	pixels := ppu.pixelLine()
+	assert.Len(t, pixels, WIDTH)
+	for _, pixel := range pixels {
+		assert.True(t, pixel.Visible)
+		assert.EqualValues(t, 0, pixel.Color.Color)
+	}
+}
@@ -83,17 +83,20 @@ func (ppu *PPU) m7y(data uint8) {
 // 2134h - MPYL - Signed Multiply Result (lower 8bit) (R)
 func (ppu *PPU) mpyl() uint8 {
 	LL, _, _ := bit.SplitUint32(ppu.m7.signedMutlResult)
+	ppu.ppu1OpenBus = LL
 	return LL
 }
 
 // 2135h - MPYM - Signed Multiply Result (middle 8bit) (R)
 func (ppu *PPU) mpym() uint8 {
 	_, MM, _ := bit.SplitUint32(ppu.m7.signedMutlResult)
+	ppu.ppu1OpenBus = MM
 	return MM
 }
 
 // 2136h - MPYH - Signed Multiply Result (upper 8bit) (R)
 func (ppu *PPU) mpyh() uint8 {
 	_, _, HH := bit.SplitUint32(ppu.m7.signedMutlResult)
+	ppu.ppu1OpenBus = HH
 	return HH
 }
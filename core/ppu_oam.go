@@ -1,5 +1,10 @@
 package core
 
+const (
+	maxSpritesPerLine = 32 // number of sprites the range evaluation can hold for a single line
+	maxTilesPerLine   = 34 // number of 8x8 sprite tiles the time evaluation can fetch for a single line
+)
+
 // oam represents the object attribute memory, two tables (512 + 32 Bytes)
 type oam struct {
 	bytes           [0x200 + 0x20]byte // raw bytes for the object attribute memory
@@ -41,6 +46,9 @@ func (ppu *PPU) oamaddh(data uint8) {
 // Write to ODD address<200h  -->  set WORD[addr-1] = Data*256 + OAM_Lsb
 // Write to ANY address>1FFh  -->  set BYTE[addr] = Data
 // Read from ANY address      -->  return BYTE[addr]
+//
+// During active display the OAM is read by the sprite evaluation, the bytes which would be written
+// go to the address it is reading instead (the lsb is still memorized)
 func (ppu *PPU) oamdata(data uint8) {
 	if ppu.oam.addr%2 == 0 {
 		// Write to the temporary variable
@@ -49,10 +57,15 @@ func (ppu *PPU) oamdata(data uint8) {
 
 	// Check if we are going to write in the first or second table
 	// 0x1FF == 511
-	if ppu.oam.addr > 0x1FF {
+	switch {
+	case ppu.oam.addr <= 0x1FF && ppu.oam.addr%2 == 0:
+		// Only the lsb is memorized
+	case !ppu.writeAllowed("OAM", ppu.oam.addr, ppu.oamWritable):
+		ppu.oam.bytes[ppu.oamAccessAddr()] = data
+	case ppu.oam.addr > 0x1FF:
 		// Writing in the second table
 		ppu.oam.bytes[ppu.oam.addr] = data
-	} else if ppu.oam.addr%2 == 1 {
+	default:
 		// Writing in the first table
 		ppu.oam.write(ppu.oam.addr-1, ppu.oam.lsb, data)
 	}
@@ -60,10 +73,22 @@ func (ppu *PPU) oamdata(data uint8) {
 	ppu.oam.incrAddr()
 }
 
+// oamAccessAddr returns the OAM address read by the PPU during active display
+// The range evaluation reads the first table entry of a sprite every 2 dots starting with the first sprite,
+// it is approximated with the address of the last evaluated sprite for the rest of the line
+func (ppu *PPU) oamAccessAddr() uint16 {
+	dot := ppu.dot()
+	if dot > 255 {
+		dot = 255
+	}
+	return ((ppu.oam.firstSprite() + dot/2) & 0x7F) << 2
+}
+
 // 2138 - RDOAM - OAM Data Read (R)
 func (ppu *PPU) rdoam() uint8 {
 	res := ppu.oam.read(ppu.oam.addr)
 	ppu.oam.incrAddr()
+	ppu.ppu1OpenBus = res
 	return res
 }
 
@@ -112,18 +137,41 @@ func (o *oam) allSprites() []sprite {
 	return sprites
 }
 
-// intersectingSprites returns all the sprites currently intersecting the v-line
-func (o *oam) intersectingSprites(vCounter uint16) []sprite {
-	sprites := make([]sprite, 0, 128)
+// firstSprite returns the index of the sprite with the highest priority
+// When the priority rotation bit of OAMADDH is set, the sprite selected by the OAM address reload value gets
+// the highest priority, otherwise sprite 0 has the highest priority
+func (o *oam) firstSprite() uint16 {
+	if !o.priorityBit {
+		return 0
+	}
+
+	return (o.lastWrittenAddr >> 1) & 0x7F
+}
+
+// reload sets the oam address back to the last written address, it happens at the beginning of the VBlank
+func (o *oam) reload() {
+	o.addr = o.lastWrittenAddr
+}
+
+// intersectingSprites performs the range evaluation phase: it returns at most 32 sprites intersecting the v-line
+// in priority order (starting with the first sprite) and whether more than 32 sprites were found on the line
+func (o *oam) intersectingSprites(vCounter uint16, objInterlace bool, oddField bool) ([]sprite, bool) {
+	sprites := make([]sprite, 0, maxSpritesPerLine)
+	first := o.firstSprite()
 
-	for i := 0; i < 128; i++ {
-		s := o.sprite(uint16(i))
-		if s.IntersectsLine(vCounter) {
-			sprites = append(sprites, o.sprite(uint16(i)))
+	for i := uint16(0); i < 128; i++ {
+		s := o.sprite((first + i) & 0x7F)
+		if _, ok := s.rowAt(vCounter, objInterlace, oddField); !ok || !s.onScreen() {
+			continue
 		}
+
+		if len(sprites) == maxSpritesPerLine {
+			return sprites, true
+		}
+		sprites = append(sprites, s)
 	}
 
-	return sprites
+	return sprites, false
 }
 
 // sprite gets the sprite at the given index
@@ -10,7 +10,7 @@ import (
 
 func TestOamLastWrittenAddr(t *testing.T) {
 	// Set oam addr register to 0x104
-	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory())
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
 	ppu.oamaddl(0x04)
 	ppu.oamaddh(0x1)
 
@@ -32,7 +32,7 @@ func TestOamWritesAndReads(t *testing.T) {
 	// Write 1, read, read, Write 2, read, write 3
 	// => OAM is 00 00 01 02 01 03, rather than 01 00 00 02 00 03 as you might expect.
 
-	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory())
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
 	ppu.oamdata(0x1)
 	assert.EqualValues(t, 0, ppu.rdoam())
 	assert.EqualValues(t, 0, ppu.rdoam())
@@ -42,3 +42,49 @@ func TestOamWritesAndReads(t *testing.T) {
 
 	assert.EqualValues(t, []byte{0x00, 0x00, 0x01, 0x02, 0x01, 0x03}, ppu.oam.bytes[:6])
 }
+
+// setSprite writes a small 8x8 sprite at the given index in the OAM
+func setSprite(ppu *PPU, idx int, x, y uint8) {
+	ppu.oam.bytes[4*idx] = x
+	ppu.oam.bytes[4*idx+1] = y
+}
+
+func TestSpriteRangeOver(t *testing.T) {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+	for i := 0; i < 128; i++ {
+		setSprite(ppu, i, 0, 0xF0)
+	}
+	for i := 0; i < 40; i++ {
+		setSprite(ppu, i, uint8(i), 10)
+	}
+
+	sprites, rangeOver := ppu.oam.intersectingSprites(12, false, false)
+	assert.True(t, rangeOver)
+	assert.Len(t, sprites, maxSpritesPerLine)
+	assert.EqualValues(t, 0, sprites[0].x)
+
+	// With priority rotation the first sprite is selected from the OAM address
+	ppu.oamaddl(20)
+	ppu.oamaddh(0x80)
+	sprites, _ = ppu.oam.intersectingSprites(12, false, false)
+	assert.EqualValues(t, 10, sprites[0].x)
+}
+
+func TestSpriteTimeOver(t *testing.T) {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+	ppu.vCounter = 12
+	// 5 sprites of 64x64 need 40 tiles
+	ppu.obsel(0x40)
+	for i := 0; i < 128; i++ {
+		setSprite(ppu, i, 0, 0xF0)
+	}
+	for i := 0; i < 5; i++ {
+		setSprite(ppu, i, uint8(i), 10)
+		ppu.oam.bytes[0x200+i/4] |= 0x2 << (2 * (i % 4))
+	}
+
+	ppu.spritesPixelLine()
+	assert.False(t, ppu.status.rangeOver)
+	assert.True(t, ppu.status.timeOver)
+	assert.EqualValues(t, 0x81, ppu.stat77())
+}
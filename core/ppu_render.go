@@ -3,71 +3,165 @@ package core
 import (
 	"image"
 
+	"github.com/snes-emu/gose/bit"
+	"github.com/snes-emu/gose/config"
 	"github.com/snes-emu/gose/log"
 	"github.com/snes-emu/gose/render"
 )
 
-const WIDTH = 250
-const HEIGHT = 250
+const WIDTH = 256
+const HIRES_WIDTH = 512
+const INTERLACE_HEIGHT = 478
 
 const TILE_SIZE = 8
 
+// ScreenWidth returns the width of the frames rendered by the PPU
+// Frames are 512 pixels wide so hi-res modes can be displayed, unless hi-res blending is enabled
+func ScreenWidth() uint16 {
+	if config.HiresBlend() {
+		return WIDTH
+	}
+	return HIRES_WIDTH
+}
+
+// ScreenHeight returns the height of the frames rendered by the PPU
+// Frames are always tall enough to display interlaced frames, every line covers 2 rows of the screen
+func ScreenHeight() uint16 {
+	return INTERLACE_HEIGHT
+}
+
 func (ppu *PPU) renderLine() {
 	if ppu.screen == nil {
-		ppu.screen = render.NewScreen(WIDTH, HEIGHT)
+		ppu.screen = render.NewScreen(ScreenWidth(), ScreenHeight())
 	}
 
-	ppu.vCounter = (ppu.vCounter + 1) % ppu.VDisplayEnd()
+	// The line that just ended is rendered with the registers latched at its start,
+	// with the dot renderer only the pixels which were not output yet are rendered
+	if l := ppu.latched; l != nil {
+		l.setScreenLine(l.vCounter-1, l.pixelLine())
+		ppu.latched = nil
+	}
+	if ppu.dotLine != nil {
+		ppu.finishDotLine()
+	}
 
-	if ppu.vCounter < ppu.screen.Height {
-		ppu.screen.SetPixelLine(ppu.vCounter, ppu.backdropPixelLine())
-		ppu.screen.SetPixelLine(ppu.vCounter, ppu.spritesToPixelLine(ppu.oam.intersectingSprites(ppu.vCounter)))
-		//TODO handle background modes and display backgrounds accordingly
-		//We only display BG1 for now
-		ppu.screen.SetPixelLine(ppu.vCounter, ppu.backgroundToPixelLine(0))
+	ppu.vCounter = (ppu.vCounter + 1) % (ppu.VDisplayEnd() + 1)
+
+	if ppu.displayedLine() {
+		ppu.updateMosaicLine()
+		if ppu.dotRenderer {
+			ppu.startDotLine()
+		} else {
+			ppu.latchRegisters()
+		}
 	}
 
 	if ppu.vCounter == ppu.VDisplay()+1 {
 		ppu.renderer.Render(ppu.screen)
+		if ppu.pacer != nil {
+			ppu.pacer.wait()
+		}
 		log.Debug("VBlank")
+		if !ppu.display.forceBlank {
+			ppu.oam.reload()
+		}
 		ppu.cpu.enterVblank()
 	}
 
 	if ppu.vCounter == 0 {
 		log.Debug("End of VBlank")
+		// sprite overflow flags are reset at the end of the VBlank
+		ppu.status.rangeOver = false
+		ppu.status.timeOver = false
+		ppu.status.interlaceFrame = !ppu.status.interlaceFrame
+		// in interlace mode the fields don't have the same number of lines
+		if ppu.pacer != nil {
+			ppu.pacer.setFrameDuration(ppu.frameCycles(), ppu.masterClock())
+		}
+		ppu.frame++
 		ppu.cpu.leavVblank()
 	}
 }
 
-// spritesToPixelLine takes the given sprites and outputs a row of pixels that intersects with the vCounter
-// TODO: vertical flip
-// TODO: horizontal flip
-// TODO: Update ppu status
-// TODO: limit to 32 sprites
-// TODO: limit to 34 tiles
-// TODO: respect sprite priority
+// displayedLine returns true if the current line is displayed (line 0 is never displayed)
+func (ppu *PPU) displayedLine() bool {
+	return ppu.vCounter > 0 && ppu.vCounter <= ppu.VDisplay()
+}
+
+// setScreenLine writes a line of pixels on the screen
+// In interlace mode every field only draws every other row so both fields are woven together into a full height frame,
+// otherwise (or if weaving is disabled) the line is doubled
+func (ppu *PPU) setScreenLine(line uint16, pixels []render.Pixel) {
+	row := line << 1
+	if ppu.display.vScanning && config.InterlaceWeave() {
+		ppu.screen.SetPixelLine(row|bit.BoolToUint16(ppu.status.interlaceFrame), pixels)
+		return
+	}
+
+	ppu.screen.SetPixelLine(row, pixels)
+	ppu.screen.SetPixelLine(row+1, pixels)
+}
+
+// spritesToPixelLine performs the time evaluation phase for the given sprites and outputs a row of pixels that intersects with the vCounter
+// The sprites are expected in priority order as returned by the range evaluation phase.
+// Tiles are fetched starting from the last sprite, if more than 34 tiles are needed the remaining ones are dropped
+// (which means the sprites with the highest priority are the ones disappearing) and the time over flag is set.
+// Sprites with a higher priority are drawn on top of the ones with a lower priority, regardless of their priority bits.
 func (ppu *PPU) spritesToPixelLine(sprites []sprite) []render.Pixel {
 	// Initialize pixel line
 	pixels := make([]render.Pixel, WIDTH)
+	tileCount := 0
+
+	for i := len(sprites) - 1; i >= 0; i-- {
+		sprite := sprites[i]
+
+		row, ok := sprite.rowAt(ppu.vCounter, ppu.display.objVDisplay, ppu.status.interlaceFrame)
+		if !ok {
+			continue
+		}
 
-	for _, sprite := range sprites {
 		// Y coordinate of the tile containing the line
-		yTile := (ppu.vCounter - sprite.y) / TILE_SIZE
+		yTile := row / TILE_SIZE
 
 		// Y coordinate of the line in the tile
-		y := (ppu.vCounter - sprite.y) % TILE_SIZE
+		y := row % TILE_SIZE
 
 		// Loop over all the tiles contained in the sprite
-		for xTile := uint16(0); xTile < sprite.hSize/TILE_SIZE; xTile++ {
+		tilesWide := sprite.hSize / TILE_SIZE
+		for t := uint16(0); t < tilesWide; t++ {
+			tileX := (sprite.x + t*TILE_SIZE) & 0x1FF
 
+			// Tiles that are fully on the left of the screen are not fetched
+			if tileX >= 256 && tileX+TILE_SIZE <= 512 {
+				continue
+			}
+
+			tileCount++
+			if tileCount > maxTilesPerLine {
+				ppu.status.timeOver = true
+				return pixels
+			}
+
+			xTile := t
+			if sprite.hFlip {
+				xTile = tilesWide - 1 - t
+			}
 			tile := sprite.tileAt(xTile, yTile)
 
 			// Go through all the pixels in the tile line
 			for x, color := range ppu.tileRowColor(tile, y) {
 				// Only change the pixel if the color is not transparent
-				if !color.Transparent {
-					lineIdx := sprite.x + uint16(x) + (TILE_SIZE * xTile)
-					pixels[lineIdx%WIDTH] = render.Pixel{
+				if color.Transparent {
+					continue
+				}
+
+				px := uint16(x)
+				if sprite.hFlip {
+					px = TILE_SIZE - 1 - px
+				}
+
+				if lineIdx := (tileX + px) & 0x1FF; lineIdx < WIDTH {
+					pixels[lineIdx] = render.Pixel{
 						Color:    color,
 						Visible:  true,
 						Priority: sprite.priority,
@@ -80,67 +174,123 @@ func (ppu *PPU) spritesToPixelLine(sprites []sprite) []render.Pixel {
 	return pixels
 }
 
-//backgroundToPixelLine the row of pixel of the background bgIndex that intersects with vCounter
-//TODO: vertical flip
-//TODO: horizontal flip
-//TODO: priority
+// spritesPixelLine evaluates the sprites on the current line, updates the sprite overflow flags and returns the resulting pixels
+func (ppu *PPU) spritesPixelLine() []render.Pixel {
+	sprites, rangeOver := ppu.oam.intersectingSprites(ppu.vCounter, ppu.display.objVDisplay, ppu.status.interlaceFrame)
+	if rangeOver {
+		ppu.status.rangeOver = true
+	}
+
+	return ppu.spritesToPixelLine(sprites)
+}
+
+// backgroundToPixelLine the row of pixel of the background bgIndex that intersects with vCounter
+// Both scroll values are 10-bit: the background wraps around after 1024 pixels (or less for smaller tilemaps)
+// In modes 5 and 6 the returned line is 512 pixels wide (and the background has twice as many lines in interlace mode)
+// In modes 2, 4 and 6 the scroll values of BG1 and BG2 can be changed for every column (see columnScroll)
+// With mosaic, the background is displayed with blocks of up to 16x16 pixels
 func (ppu *PPU) backgroundToPixelLine(bgIndex uint8) []render.Pixel {
-	// Initialize pixel line
-	pixels := make([]render.Pixel, WIDTH)
+	l := ppu.newBgLine(bgIndex)
+	ppu.renderBgLine(l, uint16(len(l.pixels)))
+	return l.pixels
+}
+
+// bgLine is a row of pixels of a background being rendered
+type bgLine struct {
+	bgIndex uint8
+	pixels  []render.Pixel // pixels of the row, 512 pixels wide in modes 5 and 6
+	line    uint16         // line of the background displayed on the row (without scrolling)
+	next    uint16         // index of the next pixel to render
+
+	// Scroll values are only computed again when we change column and background tiles when we cross a tile boundary
+	column, xBgTile, yBgTile uint16
+	hScroll, y               uint16
+	bgTile                   bgTile
+}
 
+// newBgLine returns the row of pixels of the background bgIndex that intersects with vCounter, with no pixel rendered yet
+func (ppu *PPU) newBgLine(bgIndex uint8) *bgLine {
 	bg := ppu.backgroundData.bg[bgIndex]
-	hTileSize, vTileSize := bg.tileSize()
 
-	//Y coordinate of the background tile containing the line
-	yBgTile := (ppu.vCounter + bg.verticalScroll) / vTileSize
+	// In interlace mode, hi-res backgrounds are rendered with 448 or 478 lines: each field displays every other line
+	line := ppu.vCounter
+	if bg.mosaic {
+		line = ppu.backgroundData.mosaicLine
+	}
+	if ppu.bgInterlace() {
+		line = line<<1 | bit.BoolToUint16(ppu.status.interlaceFrame)
+	}
 
-	//Y coordinate of the base tile inside the background tile containing the line
-	yTile := (ppu.vCounter + bg.verticalScroll - yBgTile*vTileSize) / TILE_SIZE
+	return &bgLine{
+		bgIndex: bgIndex,
+		pixels:  make([]render.Pixel, ppu.backgroundWidth()),
+		line:    line,
+		column:  0xFFFF,
+		xBgTile: 0xFFFF,
+		yBgTile: 0xFFFF,
+	}
+}
 
-	//Y coordinate of the line inside the base tile
-	y := (ppu.vCounter + bg.verticalScroll - yBgTile*vTileSize - yTile*TILE_SIZE)
+// renderBgLine renders the pixels of the row until end (excluded) using the current register values
+func (ppu *PPU) renderBgLine(l *bgLine, end uint16) {
+	width := uint16(len(l.pixels))
+	bg := ppu.backgroundData.bg[l.bgIndex]
+	hTileSize, vTileSize := ppu.tileSize(l.bgIndex)
 
-	//go through the background tiles
-	hStart := bg.horizontalScroll
-	hEnd := hStart + uint16(WIDTH)
-	for xBgTile := hStart / hTileSize; xBgTile < hEnd/hTileSize+1; xBgTile++ {
+	mosaicSize := uint16(1)
+	if bg.mosaic {
+		mosaicSize += uint16(ppu.backgroundData.mosaicSize)
+	}
 
-		//get the background tile at these coordinates
-		bgTile := ppu.tileFromBackground(bgIndex, xBgTile, yBgTile)
+	for ; l.next < end && l.next < width; l.next++ {
+		lineIdx := l.next
 
-		// Loop over all the base tiles contained in the background tile
-		for xTile := uint16(0); xTile < bgTile.hSize/TILE_SIZE; xTile++ {
+		// Columns are 8 low-res pixels wide and aligned on the fine horizontal scroll
+		lowResX := lineIdx
+		if width == HIRES_WIDTH {
+			lowResX >>= 1
+		}
 
-			tile := bgTile.tileAt(xTile, yTile)
+		// With mosaic, every pixel of a block has the color of the first pixel of the block
+		if offset := lowResX % mosaicSize; offset != 0 {
+			l.pixels[lineIdx] = l.pixels[lineIdx-offset*(width/WIDTH)]
+			continue
+		}
 
-			// Loop over all the pixels in the current tile
-			for x, color := range ppu.tileRowColor(tile, y) {
-				lineIdx := uint16(x) + xTile*TILE_SIZE + xBgTile*hTileSize - bg.horizontalScroll
-				if !color.Transparent && lineIdx >= 0 && lineIdx < WIDTH {
-					pixels[lineIdx] = render.Pixel{
-						Color:   color,
-						Visible: true,
-					}
-					if bgTile.priority {
-						pixels[lineIdx].Priority = 1
-					}
+		if c := (lowResX + bg.horizontalScroll&7) / TILE_SIZE; c != l.column {
+			l.column = c
+			var vScroll uint16
+			l.hScroll, vScroll = ppu.columnScroll(l.bgIndex, l.column)
 
-				}
+			// In hi-res modes the horizontal scroll is counted in low-res pixels
+			if width == HIRES_WIDTH {
+				l.hScroll <<= 1
 			}
+
+			// Y coordinate of the line in the background
+			l.y = (l.line + vScroll) & scrollMask
 		}
-	}
 
-	return pixels
-}
+		// X coordinate of the pixel in the background
+		x := (lineIdx + l.hScroll) & scrollMask
 
-func (ppu *PPU) backdropPixelLine() []render.Pixel {
-	pixels := make([]render.Pixel, WIDTH)
-	backdropPixel := ppu.backdropPixel()
-	for i := range pixels {
-		pixels[i] = backdropPixel
-	}
+		if x/hTileSize != l.xBgTile || l.y/vTileSize != l.yBgTile {
+			l.xBgTile, l.yBgTile = x/hTileSize, l.y/vTileSize
+			l.bgTile = ppu.tileFromBackground(l.bgIndex, l.xBgTile, l.yBgTile)
+		}
 
-	return pixels
+		tile, xTile, yTile := l.bgTile.pixelAt(x%hTileSize, l.y%vTileSize)
+		color := ppu.tileColor(tile, xTile, yTile)
+		if color.Transparent {
+			continue
+		}
+
+		l.pixels[lineIdx] = render.Pixel{
+			Color:    color,
+			Visible:  true,
+			Priority: bit.BoolToUint8(l.bgTile.priority),
+		}
+	}
 }
 
 func (ppu *PPU) spriteToImage(sprite sprite) image.Image {
@@ -180,11 +330,9 @@ func (ppu *PPU) Sprites() []image.Image {
 }
 
 func (ppu *PPU) bgToImage(bgIndex uint8) image.Image {
-	bg := ppu.backgroundData.bg[bgIndex]
-
 	//create an image to fit the background
 	sizeInTile := uint16(64)
-	hTileSize, vTileSize := bg.tileSize()
+	hTileSize, vTileSize := ppu.tileSize(bgIndex)
 	hSize := sizeInTile * hTileSize
 	vSize := sizeInTile * vTileSize
 	img := image.NewRGBA(image.Rectangle{
@@ -201,25 +349,14 @@ func (ppu *PPU) bgToImage(bgIndex uint8) image.Image {
 			//get the background tile at these coordinates
 			bgTile := ppu.tileFromBackground(bgIndex, xBgTile, yBgTile)
 
-			// Loop over all the base tiles contained in the background tile
-			for yTile := uint16(0); yTile < bgTile.vSize/TILE_SIZE; yTile++ {
-				for xTile := uint16(0); xTile < bgTile.hSize/TILE_SIZE; xTile++ {
-
-					tile := bgTile.tileAt(xTile, yTile)
-
-					// Loop over all the pixels in the current tile
-					for y := uint16(0); y < bgTile.vSize; y++ {
-						for x, color := range ppu.tileRowColor(tile, y) {
-							if !color.Transparent {
-								img.Set(
-									int(xBgTile*bgTile.hSize+xTile*TILE_SIZE+uint16(x)),
-									int(yBgTile*bgTile.vSize+yTile*TILE_SIZE+uint16(y)),
-									color,
-								)
-							}
-						}
+			// Loop over all the pixels in the background tile
+			for y := uint16(0); y < bgTile.vSize; y++ {
+				for x := uint16(0); x < bgTile.hSize; x++ {
+					tile, xTile, yTile := bgTile.pixelAt(x, y)
+					color := ppu.tileColor(tile, xTile, yTile)
+					if !color.Transparent {
+						img.Set(int(xBgTile*bgTile.hSize+x), int(yBgTile*bgTile.vSize+y), color)
 					}
-
 				}
 			}
 
@@ -0,0 +1,261 @@
+package core
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/snes-emu/gose/apu"
+	"github.com/snes-emu/gose/bit"
+	"github.com/snes-emu/gose/io"
+	"github.com/snes-emu/gose/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetScreenLine(t *testing.T) {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+	line := []render.Pixel{{Visible: true, Color: render.BGR555{Color: 0x1F}}}
+	row := func(r int) uint16 {
+		return ppu.screen.Pixels[r*int(ppu.screen.Width)].Color.Color
+	}
+
+	// Lines are doubled when interlace is disabled
+	ppu.setScreenLine(3, line)
+	assert.EqualValues(t, 0x1F, row(6))
+	assert.EqualValues(t, 0x1F, row(7))
+
+	// Interlaced fields are woven together
+	ppu.setini(0x01)
+	line[0].Color.Color = 0x3E0
+	ppu.setScreenLine(3, line)
+	assert.EqualValues(t, 0x3E0, row(6))
+	assert.EqualValues(t, 0x1F, row(7))
+
+	ppu.status.interlaceFrame = true
+	line[0].Color.Color = 0x7C00
+	ppu.setScreenLine(3, line)
+	assert.EqualValues(t, 0x3E0, row(6))
+	assert.EqualValues(t, 0x7C00, row(7))
+}
+
+func TestInterlaceFrameFlag(t *testing.T) {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+	ppu.cpu = newTestCPU()
+	ppu.vCounter = ppu.VDisplayEnd()
+
+	ppu.renderLine()
+	assert.EqualValues(t, 0x82, ppu.stat78())
+
+	ppu.vCounter = ppu.VDisplayEnd()
+	ppu.renderLine()
+	assert.EqualValues(t, 0x02, ppu.stat78())
+}
+
+func TestVRAMReadDuringActiveDisplay(t *testing.T) {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+	ppu.vram.bytes[0x20], ppu.vram.bytes[0x21] = 0x34, 0x12
+
+	// The screen is forced blank on power up
+	ppu.vmaddl(0x10)
+	assert.EqualValues(t, 0x34, ppu.rdvraml())
+
+	// The VRAM can't be read during active display
+	ppu.inidisp(0x0F)
+	ppu.vmaddl(0x10)
+	assert.EqualValues(t, 0x00, ppu.rdvraml())
+
+	// The VRAM can be read during the VBlank
+	ppu.vCounter = ppu.VDisplay() + 1
+	ppu.vmaddl(0x10)
+	assert.EqualValues(t, 0x34, ppu.rdvraml())
+}
+
+func TestAccurateAccessInstruction(t *testing.T) {
+	assert.NoError(t, flag.Set("accurate-access", "true"))
+	defer flag.Set("accurate-access", "false")
+
+	cpu := newTestConsole(false)
+	ppu := cpu.ppu
+	ppu.inidisp(0x0F)
+	ppu.vCounter = 100
+
+	// STA $2122 started before the HBlank writes during it: the write ends 30 master cycles after the start
+	for i, b := range []uint8{0x8D, 0x22, 0x21} {
+		cpu.memory.SetByteBank(b, 0x00, 0x0100+uint16(i))
+	}
+	cpu.mFlag, cpu.C = true, 0x55
+	ppu.cgadd(0)
+	ppu.cgdata(0x11)
+
+	cpu.PC, cpu.cycles = 0x0100, 272*4
+	cpu.execOpcode()
+	assert.EqualValues(t, 0x11, ppu.cgram.bytes[0])
+	assert.EqualValues(t, 0x55, ppu.cgram.bytes[1])
+
+	// STA $2122 started during the HBlank writes after it
+	ppu.cgadd(0)
+	ppu.cgdata(0x22)
+	cpu.PC, cpu.cycles, cpu.C = 0x0100, 16*4, 0x66
+	cpu.execOpcode()
+	assert.EqualValues(t, 0x11, ppu.cgram.bytes[0])
+	assert.EqualValues(t, 0x55, ppu.cgram.bytes[1])
+}
+
+func TestAccurateAccess(t *testing.T) {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+	ppu.cpu = newTestCPU()
+	ppu.inidisp(0x0F)
+	ppu.vmain(0x00)
+	ppu.vCounter = 100
+	ppu.cpu.cycles = 400
+
+	// Writes are performed at any time by default
+	ppu.vmdatal(0x12)
+	ppu.oamdata(0x34)
+	ppu.oamdata(0x56)
+	ppu.cgdata(0x78)
+	ppu.cgdata(0x1A)
+	assert.EqualValues(t, 0x12, ppu.vram.bytes[0])
+	assert.EqualValues(t, 0x34, ppu.oam.bytes[0])
+	assert.EqualValues(t, 0x78, ppu.cgram.bytes[0])
+
+	assert.NoError(t, flag.Set("accurate-access", "true"))
+	defer flag.Set("accurate-access", "false")
+
+	// Writes are dropped during active display but the addresses are still incremented
+	ppu.vmaddl(0)
+	ppu.oamaddl(0)
+	ppu.cgadd(0)
+	ppu.vmdatal(0xAA)
+	ppu.oamdata(0xAA)
+	ppu.oamdata(0xAB)
+	ppu.cgdata(0xAA)
+	ppu.cgdata(0x2A)
+	assert.EqualValues(t, 0x12, ppu.vram.bytes[0])
+	assert.EqualValues(t, 0x34, ppu.oam.bytes[0])
+	assert.EqualValues(t, 0x78, ppu.cgram.bytes[0])
+	assert.EqualValues(t, 1, ppu.vram.addr)
+	assert.EqualValues(t, 2, ppu.oam.addr)
+	assert.EqualValues(t, 2, ppu.cgram.addr)
+
+	// The OAM byte goes to the sprite read by the range evaluation: sprite 50 at dot 100
+	assert.EqualValues(t, 0xAB, ppu.oam.bytes[50*4])
+	// the first sprite is 10 with the priority rotation
+	ppu.oamaddl(20)
+	ppu.oamaddh(0x80)
+	ppu.oamdata(0xAC)
+	ppu.oamdata(0xAD)
+	assert.EqualValues(t, 0xAD, ppu.oam.bytes[(10+50)*4])
+	ppu.oamaddh(0x00)
+
+	// The lsb of the OAM and CGRAM words is memorized even if the write is dropped
+	ppu.oamaddl(0)
+	ppu.cgadd(0)
+	ppu.oamdata(0x11)
+	ppu.cgdata(0x33)
+	ppu.vCounter = ppu.VDisplay() + 1
+	ppu.oamdata(0x22)
+	ppu.cgdata(0x44)
+	assert.EqualValues(t, []uint8{0x11, 0x22}, ppu.oam.bytes[:2])
+	assert.EqualValues(t, []uint8{0x33, 0x44}, ppu.cgram.bytes[:2])
+	ppu.vCounter = 100
+
+	// The CGRAM can be written during the HBlank
+	ppu.cpu.cycles = 1200
+	ppu.cgadd(0)
+	ppu.cgdata(0xBB)
+	ppu.cgdata(0x3B)
+	assert.EqualValues(t, 0xBB, ppu.cgram.bytes[0])
+	ppu.vmdatal(0xBB)
+	assert.EqualValues(t, 0x00, ppu.vram.bytes[2])
+
+	// Everything can be written during the VBlank and forced blank
+	ppu.vCounter = ppu.VDisplay() + 1
+	ppu.vmaddl(0)
+	ppu.vmdatal(0xCC)
+	assert.EqualValues(t, 0xCC, ppu.vram.bytes[0])
+
+	ppu.vCounter = 100
+	ppu.inidisp(0x80)
+	ppu.oamaddl(0)
+	ppu.oamdata(0xDD)
+	ppu.oamdata(0xDD)
+	assert.EqualValues(t, 0xDD, ppu.oam.bytes[0])
+}
+
+func TestAPUClock(t *testing.T) {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+	ppu.cpu = newTestCPU()
+	ppu.cpu.ppu = ppu
+	ppu.cpu.memory.apu = apu.New(io.NewRegisterFactory())
+	ppu.cpu.memory.apu.SetMasterClock(ppu.masterClock())
+
+	// The APU is driven by the master cycles of the CPU, the cycles of a whole frame are converted
+	for i := 0; i < int(ppu.frameCycles())/4; i++ {
+		ppu.cpu.advance(4)
+	}
+	assert.EqualValues(t, uint64(ppu.frameCycles())*apu.ClockRate/MasterClockNTSC, ppu.cpu.memory.apu.Cycles())
+}
+
+func TestRegion(t *testing.T) {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+	ppu.cpu = newTestCPU()
+	ppu.pacer = newPacer(ppu.frameCycles(), ppu.masterClock())
+
+	assert.EqualValues(t, 262*1364, ppu.frameCycles())
+	assert.InDelta(t, time.Second/60, ppu.pacer.frameDuration, float64(time.Millisecond))
+	assert.EqualValues(t, 0x00, ppu.stat78()&0x10)
+
+	ppu.setRegion(true)
+	assert.EqualValues(t, 312*1364, ppu.frameCycles())
+	assert.InDelta(t, time.Second/50, ppu.pacer.frameDuration, float64(time.Millisecond))
+	assert.EqualValues(t, 0x10, ppu.stat78()&0x10)
+
+	// PAL frames have 312 lines
+	ppu.pacer = nil
+	lines := 0
+	for ppu.vCounter = 0; ; lines++ {
+		ppu.renderLine()
+		if ppu.vCounter == 0 {
+			break
+		}
+	}
+	assert.Equal(t, 311, lines)
+
+	// The number of displayed lines only depends on the overscan mode
+	assert.EqualValues(t, 224, ppu.VDisplay())
+	ppu.setini(0x04)
+	assert.EqualValues(t, 239, ppu.VDisplay())
+}
+
+func TestLatchRegisters(t *testing.T) {
+	ppu := newTestBgPPU(false)
+	ppu.cpu = newTestCPU()
+	ppu.vram.bytes[0], ppu.vram.bytes[1] = bit.SplitUint16(2)
+	ppu.tm(0x01)
+	row := func(r int) uint16 {
+		return ppu.screen.Pixels[r*int(ppu.screen.Width)].Color.Color
+	}
+
+	// Line 1 starts with the tile displayed on the first pixel
+	ppu.vCounter = 0
+	ppu.renderLine()
+
+	// Registers written during the line only affect the next one
+	ppu.backgroundData.bg[0].horizontalScroll = 8
+	ppu.renderLine()
+	assert.EqualValues(t, 5, row(0))
+
+	ppu.renderLine()
+	assert.EqualValues(t, 0, row(2))
+
+	// VRAM written during the line (without the accurate access option) only affects the next one too
+	ppu.backgroundData.bg[0].horizontalScroll = 0
+	ppu.renderLine()
+	ppu.vmaddl(0)
+	ppu.vmdatal(3)
+	ppu.renderLine()
+	assert.EqualValues(t, 5, row(6))
+	ppu.renderLine()
+	assert.EqualValues(t, 6, row(8))
+}
@@ -16,66 +16,125 @@ type status struct {
 	interlaceFrame bool   // Interlace mode current frame
 }
 
+// HCounter returns the current dot of the line (from 0 to 339)
 func (ppu *PPU) HCounter() uint16 {
-	return ppu.hCounter
+	return ppu.dot()
 }
 
+// VCounter returns the current line of the frame
 func (ppu *PPU) VCounter() uint16 {
 	return ppu.vCounter
 }
 
+// shortLine returns true on line 240 of the odd frames in NTSC non-interlace mode, this line is 4 master cycles shorter
+func (ppu *PPU) shortLine() bool {
+	return !ppu.status.palMode && !ppu.display.vScanning && ppu.status.interlaceFrame && ppu.vCounter == 240
+}
+
+// longLine returns true on the last line of the odd frames in PAL interlace mode, this line is 4 master cycles longer
+func (ppu *PPU) longLine() bool {
+	return ppu.status.palMode && ppu.display.vScanning && ppu.status.interlaceFrame && ppu.vCounter == VMaxPAL
+}
+
+// lineCycles returns the number of master cycles of the current line
+func (ppu *PPU) lineCycles() uint16 {
+	switch {
+	case ppu.shortLine():
+		return ShortLineCycles
+	case ppu.longLine():
+		return LongLineCycles
+	default:
+		return CyclesPerLine
+	}
+}
+
+// hCounterAt returns the H counter value after the given number of master cycles in the current line
+// A dot lasts 4 master cycles except for dots 323 and 327 which last 6 master cycles (they don't exist on the short line)
+// See: https://problemkaputt.de/fullsnes.htm#snestiminghvcounters
+func (ppu *PPU) hCounterAt(cycles uint16) uint16 {
+	switch {
+	case ppu.shortLine() || cycles < 323*4:
+		return cycles >> 2
+	case cycles < 323*4+6:
+		return 323
+	case cycles < 327*4+2:
+		return (cycles - 2) >> 2
+	case cycles < 327*4+8:
+		return 327
+	default:
+		return (cycles - 4) >> 2
+	}
+}
+
+// latchCounter stores the current H and V counters so they can be read with OPHCT and OPVCT
 func (ppu *PPU) latchCounter() {
-	//TODO check if bit 7 of $4201 is set before latching
-	ppu.status.hCounterLatch = ppu.hCounter
+	ppu.status.hCounterLatch = ppu.HCounter()
 	ppu.status.vCounterLatch = ppu.vCounter
 	ppu.status.latchedData = true
 }
 
+// readPPU1OpenBus is the read function of the write-only registers which are read back from the PPU1 open bus
+func (ppu *PPU) readPPU1OpenBus() uint8 {
+	return ppu.ppu1OpenBus
+}
+
 // 2137h - SLHV - Latch H/V-Counter by Software (R)
+// The counters are only latched if bit 7 of WRIO is set, the CPU open bus is read
 func (ppu *PPU) slhv() uint8 {
-	ppu.latchCounter()
-	return 0
+	if ppu.cpu.ioMemory.wrio&0x80 != 0 {
+		ppu.latchCounter()
+	}
+	return ppu.cpu.memory.openBus()
 }
 
 // 213Ch - OPHCT - Horizontal Counter Latch (R)
+// The 9-bit counter is read in two steps: the lower 8 bits first, then the 9th bit with the PPU2 open bus in bits 7-1
 func (ppu *PPU) ophct() uint8 {
 	var result uint8
 	if ppu.status.ophctFlip {
-		result = bit.HighByte(ppu.status.hCounterLatch)
+		result = bit.HighByte(ppu.status.hCounterLatch)&0x01 | ppu.ppu2OpenBus&0xFE
 	} else {
 		result = uint8(ppu.status.hCounterLatch)
 	}
 	ppu.status.ophctFlip = !ppu.status.ophctFlip
+	ppu.ppu2OpenBus = result
 	return result
 }
 
 // 213Dh - OPVCT - Vertical Counter Latch (R)
+// The 9-bit counter is read in two steps: the lower 8 bits first, then the 9th bit with the PPU2 open bus in bits 7-1
 func (ppu *PPU) opvct() uint8 {
 	var result uint8
 	if ppu.status.opvctFlip {
-		result = bit.HighByte(ppu.status.vCounterLatch)
+		result = bit.HighByte(ppu.status.vCounterLatch)&0x01 | ppu.ppu2OpenBus&0xFE
 	} else {
 		result = uint8(ppu.status.vCounterLatch)
 	}
 	ppu.status.opvctFlip = !ppu.status.opvctFlip
+	ppu.ppu2OpenBus = result
 	return result
 }
 
 // 213Eh - STAT77 - PPU1 Status and Version Number (R)
+// Bit 4 is the PPU1 open bus
 func (ppu *PPU) stat77() uint8 {
 	var result uint8 = 1 // PPU1 5C77 Version Number
+	result |= ppu.ppu1OpenBus & 0x10
 	if ppu.status.rangeOver {
 		result += 0x40
 	}
 	if ppu.status.timeOver {
 		result += 0x80
 	}
+	ppu.ppu1OpenBus = result
 	return result
 }
 
 // 213Fh - STAT78 - PPU2 Status and Version Number (R)
+// Bit 5 is the PPU2 open bus
 func (ppu *PPU) stat78() uint8 {
 	var result uint8 = 2 // PPU2 5C78 Version Number
+	result |= ppu.ppu2OpenBus & 0x20
 	if ppu.status.palMode {
 		result += 0x10
 	}
@@ -88,5 +147,6 @@ func (ppu *PPU) stat78() uint8 {
 	ppu.status.latchedData = false
 	ppu.status.ophctFlip = false
 	ppu.status.opvctFlip = false
+	ppu.ppu2OpenBus = result
 	return result
 }
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snes-emu/gose/io"
+	"github.com/snes-emu/gose/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCounterPPU() *PPU {
+	ppu := newPPU(&render.NoOpRenderer{}, io.NewRegisterFactory(), false)
+	ppu.cpu = newTestCPU()
+	ppu.cpu.ppu = ppu
+	return ppu
+}
+
+func TestHCounter(t *testing.T) {
+	ppu := newTestCounterPPU()
+
+	// master cycles -> H counter
+	for cycles, dot := range map[uint16]uint16{
+		0: 0, 3: 0, 4: 1, 1291: 322,
+		1292: 323, 1297: 323, 1298: 324, 1309: 326,
+		1310: 327, 1315: 327, 1316: 328, 1363: 339,
+	} {
+		ppu.cpu.cycles = cycles
+		assert.Equalf(t, dot, ppu.HCounter(), "cycles: %d", cycles)
+	}
+	assert.EqualValues(t, CyclesPerLine, ppu.lineCycles())
+
+	// The short line of NTSC non-interlace odd frames has no long dots
+	ppu.vCounter = 240
+	ppu.status.interlaceFrame = true
+	assert.EqualValues(t, ShortLineCycles, ppu.lineCycles())
+	for cycles, dot := range map[uint16]uint16{1292: 323, 1296: 324, 1310: 327, 1312: 328, 1359: 339} {
+		ppu.cpu.cycles = cycles
+		assert.Equalf(t, dot, ppu.HCounter(), "cycles: %d", cycles)
+	}
+
+	ppu.display.vScanning = true
+	assert.EqualValues(t, CyclesPerLine, ppu.lineCycles())
+
+	// The last line of PAL interlace odd frames has an extra dot
+	ppu.setRegion(true)
+	ppu.vCounter = VMaxPAL
+	assert.EqualValues(t, LongLineCycles, ppu.lineCycles())
+	ppu.cpu.cycles = LongLineCycles - 1
+	assert.EqualValues(t, 340, ppu.HCounter())
+}
+
+func TestCounterSequence(t *testing.T) {
+	ppu := newTestCounterPPU()
+	ppu.status.interlaceFrame = true
+	ppu.vCounter = 238
+
+	// The CPU moves to the next line once all the cycles of the current line have elapsed
+	var lines []uint16
+	var dots []uint16
+	for i := 0; i < 3*CyclesPerLine/8; i++ {
+		ppu.cpu.advance(8)
+		if len(lines) == 0 || lines[len(lines)-1] != ppu.VCounter() {
+			lines = append(lines, ppu.VCounter())
+			dots = append(dots, ppu.HCounter())
+		}
+	}
+	assert.Equal(t, []uint16{238, 239, 240, 241}, lines)
+	// Line 240 is 4 master cycles shorter so line 241 starts at dot 0 instead of dot 1
+	assert.Equal(t, []uint16{2, 1, 0, 0}, dots)
+}
+
+func TestInterlaceFields(t *testing.T) {
+	for _, pal := range []bool{false, true} {
+		ppu := newTestCounterPPU()
+		ppu.setRegion(pal)
+		ppu.pacer = newPacer(ppu.frameCycles(), ppu.masterClock())
+		ppu.setini(0x01)
+		ppu.status.interlaceFrame = true
+		ppu.vCounter = ppu.VDisplayEnd()
+
+		// The V counter goes to 0 once the last line of the field is over
+		var lastLines []uint16
+		var fieldCycles []uint32
+		for i := 0; len(lastLines) < 3; i++ {
+			lastLine := ppu.vCounter
+			ppu.cpu.cycles = ppu.lineCycles() - 2
+			ppu.cpu.advance(2)
+			if ppu.vCounter == 0 {
+				lastLines = append(lastLines, lastLine)
+				fieldCycles = append(fieldCycles, ppu.frameCycles())
+				assert.Equal(t, time.Duration(uint64(ppu.frameCycles())*uint64(time.Second)/uint64(ppu.masterClock())), ppu.pacer.frameDuration)
+			}
+		}
+
+		// The fields alternate between 262 and 263 lines (312 and 313 in PAL)
+		lines := uint16(VMaxNTSC + 1)
+		if pal {
+			lines = VMaxPAL + 1
+		}
+		assert.Equal(t, []uint16{lines - 1, lines, lines - 1}, lastLines, "pal: %v", pal)
+		assert.Equal(t, []uint32{uint32(lines+1) * CyclesPerLine, uint32(lines) * CyclesPerLine, uint32(lines+1) * CyclesPerLine}, fieldCycles)
+	}
+}
+
+func TestCounterLatchInstruction(t *testing.T) {
+	cpu := newTestConsole(false)
+	mem, ppu := cpu.memory, cpu.ppu
+
+	// LDA $2137: the counters are latched when the register is read, after the opcode and the operand fetches
+	// from the low WRAM (8 master cycles each) and the read itself (6 master cycles)
+	mem.SetByteBank(0xAD, 0x00, 0x0100)
+	mem.SetByteBank(0x37, 0x00, 0x0101)
+	mem.SetByteBank(0x21, 0x00, 0x0102)
+	cpu.PC = 0x0100
+	ppu.vCounter = 5
+	cpu.execOpcode()
+	assert.EqualValues(t, 30/4, ppu.ophct())
+	assert.EqualValues(t, 5, ppu.opvct())
+
+	// The read happens on the next line when the instruction starts at the end of the line
+	ppu.stat78()
+	cpu.PC = 0x0100
+	cpu.cycles = CyclesPerLine - 20
+	cpu.execOpcode()
+	assert.EqualValues(t, (30-20)/4, ppu.ophct())
+	assert.EqualValues(t, 6, ppu.opvct())
+}
+
+func TestCounterLatch(t *testing.T) {
+	ppu := newTestCounterPPU()
+	ppu.vCounter = 0x105
+	ppu.cpu.cycles = 1316
+
+	// SLHV latches the counters while bit 7 of WRIO is set
+	ppu.slhv()
+	// bits 7-1 of the 9th bit are the PPU2 open bus, which holds the byte read before it
+	assert.EqualValues(t, 0x48, ppu.ophct())
+	assert.EqualValues(t, 0x49, ppu.ophct())
+	assert.EqualValues(t, 0x05, ppu.opvct())
+	assert.EqualValues(t, 0x05, ppu.opvct())
+	assert.EqualValues(t, 0x40, ppu.stat78()&0x40)
+	assert.EqualValues(t, 0x00, ppu.stat78()&0x40)
+
+	// Reading STAT78 resets the flip-flops
+	ppu.ophct()
+	ppu.stat78()
+	assert.EqualValues(t, 0x48, ppu.ophct())
+
+	// Writing 0 to bit 7 of WRIO latches the counters, SLHV doesn't latch anymore
+	ppu.stat78()
+	ppu.cpu.cycles = 40
+	ppu.cpu.wrio(0x7F)
+	assert.EqualValues(t, 10, ppu.ophct())
+	assert.EqualValues(t, 0x7F, ppu.cpu.rdio())
+
+	ppu.stat78()
+	ppu.cpu.cycles = 80
+	ppu.slhv()
+	ppu.cpu.wrio(0x00)
+	assert.EqualValues(t, 10, ppu.ophct())
+
+	// The counters are latched on the 1 to 0 transition only
+	ppu.stat78()
+	ppu.cpu.wrio(0x80)
+	assert.EqualValues(t, 10, ppu.ophct())
+	ppu.stat78()
+	ppu.cpu.wrio(0x00)
+	assert.EqualValues(t, 20, ppu.ophct())
+}
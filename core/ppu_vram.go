@@ -29,18 +29,21 @@ func (ppu *PPU) vmain(data uint8) {
 // 2116 - VMADDL - VRAM Address (lower 8bit) (W)
 func (ppu *PPU) vmaddl(data uint8) {
 	ppu.vram.addr = (ppu.vram.addr & 0xff00) | uint16(data)
-	ppu.vram.prefetchWord()
+	ppu.prefetchVRAM()
 }
 
 // 2117 - VMADDH - VRAM Address (upper 8bit) (W)
 func (ppu *PPU) vmaddh(data uint8) {
 	ppu.vram.addr = bit.JoinUint16(0x00, data) | (ppu.vram.addr & 0x0ff)
-	ppu.vram.prefetchWord()
+	ppu.prefetchVRAM()
 }
 
 // 2118 - VMDATAL - VRAM Data Write (lower 8bit) (W)
 func (ppu *PPU) vmdatal(data uint8) {
-	ppu.vram.bytes[2*ppu.vram.getAddr()] = data
+	if addr := 2 * ppu.vram.getAddr(); ppu.writeAllowed("VRAM", addr, ppu.vramWritable) {
+		ppu.latchVRAM()
+		ppu.vram.bytes[addr] = data
+	}
 
 	if !ppu.vram.incrementMode {
 		// No prefetching done there
@@ -50,7 +53,10 @@ func (ppu *PPU) vmdatal(data uint8) {
 
 // 2119 - VMDATAH - VRAM Data Write (upper 8bit) (W)
 func (ppu *PPU) vmdatah(data uint8) {
-	ppu.vram.bytes[2*ppu.vram.getAddr()+1] = data
+	if addr := 2*ppu.vram.getAddr() + 1; ppu.writeAllowed("VRAM", addr, ppu.vramWritable) {
+		ppu.latchVRAM()
+		ppu.vram.bytes[addr] = data
+	}
 
 	if ppu.vram.incrementMode {
 		// No prefetching done there
@@ -79,9 +85,11 @@ func (ppu *PPU) rdvraml() uint8 {
 	res := bit.LowByte(ppu.vram.prefetch)
 
 	if !ppu.vram.incrementMode {
-		ppu.vram.prefetchAndIncrAddr()
+		ppu.prefetchVRAM()
+		ppu.vram.addr += ppu.vram.incrementAmount
 	}
 
+	ppu.ppu1OpenBus = res
 	return res
 }
 
@@ -91,20 +99,27 @@ func (ppu *PPU) rdvramh() uint8 {
 	res := bit.HighByte(ppu.vram.prefetch)
 
 	if ppu.vram.incrementMode {
-		ppu.vram.prefetchAndIncrAddr()
+		ppu.prefetchVRAM()
+		ppu.vram.addr += ppu.vram.incrementAmount
 	}
 
+	ppu.ppu1OpenBus = res
 	return res
 }
 
 func (vr *vram) prefetchWord() {
 	newAddr := vr.getAddr()
-	vr.prefetch = bit.JoinUint16(vr.bytes[2*newAddr+1], vr.bytes[2*newAddr])
+	vr.prefetch = bit.JoinUint16(vr.bytes[2*newAddr], vr.bytes[2*newAddr+1])
 }
 
-func (vr *vram) prefetchAndIncrAddr() {
-	vr.prefetchWord()
-	vr.addr += vr.incrementAmount
+// prefetchVRAM fills the prefetch register with the currently addressed VRAM word
+// The VRAM can't be read during active display, 0 is read instead
+func (ppu *PPU) prefetchVRAM() {
+	if ppu.activeDisplay() {
+		ppu.vram.prefetch = 0
+		return
+	}
+	ppu.vram.prefetchWord()
 }
 
 // getAddr returns the vram addr performing the address translation
@@ -0,0 +1,298 @@
+package core
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/snes-emu/gose/bit"
+	"github.com/snes-emu/gose/io"
+	"github.com/stretchr/testify/assert"
+)
+
+// Single-step test files hold the tests of one opcode in one mode, they are named <opcode>.<e|n>.json
+// (for instance a9.n.json holds the tests of LDA immediate in native mode)
+// The test files are not part of the repository, TestSingleStep runs them once they are fetched with
+// `git clone https://github.com/SingleStepTests/65816 core/testdata/65816` and is skipped otherwise.
+// The files of testdata/singlestep are a few hand-written tests checking the runner itself.
+var singleStepDir = flag.String("singlestep", "testdata/65816", "directory containing the 65C816 single-step test files")
+
+// maxReportedMismatches is the number of failing tests reported in details for each test file
+const maxReportedMismatches = 5
+
+// singleStepState is the state of the CPU and the RAM before or after a single-step test
+type singleStepState struct {
+	PC  uint16      `json:"pc"`
+	S   uint16      `json:"s"`
+	P   uint8       `json:"p"`
+	A   uint16      `json:"a"`
+	X   uint16      `json:"x"`
+	Y   uint16      `json:"y"`
+	DBR uint8       `json:"dbr"`
+	D   uint16      `json:"d"`
+	PBR uint8       `json:"pbr"`
+	E   uint8       `json:"e"`
+	RAM [][2]uint32 `json:"ram"` // list of (24-bit address, value) pairs
+}
+
+// singleStepTest executes one instruction from the initial state, every bus cycle is listed as (address, value, pins)
+// The pins are VDA, VPA, VPB, RWB, E, M, X and ML: the cycles with neither VDA nor VPA are internal operations
+// and RWB tells reads ('r') from writes ('w'), the value is null when nothing is read nor written
+type singleStepTest struct {
+	Name    string          `json:"name"`
+	Initial singleStepState `json:"initial"`
+	Final   singleStepState `json:"final"`
+	Cycles  [][]interface{} `json:"cycles"`
+}
+
+// newFlatMemory creates a Memory mapping the whole address space to read/write memory, without any io register
+func newFlatMemory() *Memory {
+	mem := newMemory()
+	mem.flat = make([]uint8, flatSize)
+	for region := 0; region < regionNumber; region++ {
+		mem.mmap[region] = flatRegion
+	}
+	return mem
+}
+
+// load sets the CPU registers and the RAM to the given state
+func (state singleStepState) load(cpu *CPU) {
+	cpu.setEFlag(state.E != 0)
+	cpu.cFlag = state.P&0x01 != 0
+	cpu.zFlag = state.P&0x02 != 0
+	cpu.iFlag = state.P&0x04 != 0
+	cpu.dFlag = state.P&0x08 != 0
+	cpu.vFlag = state.P&0x40 != 0
+	cpu.nFlag = state.P&0x80 != 0
+	if !cpu.eFlag {
+		cpu.xFlag = state.P&0x10 != 0
+		cpu.mFlag = state.P&0x20 != 0
+	}
+
+	cpu.PC = state.PC
+	cpu.S = state.S
+	cpu.C = state.A
+	cpu.X = state.X
+	cpu.Y = state.Y
+	cpu.DBR = state.DBR
+	cpu.D = state.D
+	cpu.K = state.PBR
+
+	for _, entry := range state.RAM {
+		cpu.memory.SetByte(uint8(entry[1]), entry[0])
+	}
+}
+
+// mismatches compares the CPU registers and the RAM with the given state
+// In emulation mode the M and X bits of P are not compared as they are always set
+func (state singleStepState) mismatches(cpu *CPU) []string {
+	var res []string
+	check := func(name string, expected, actual interface{}) {
+		if expected != actual {
+			res = append(res, fmt.Sprintf("%s: expected %#x, got %#x", name, expected, actual))
+		}
+	}
+
+	check("PC", state.PC, cpu.PC)
+	check("S", state.S, cpu.S)
+	check("A", state.A, cpu.C)
+	check("X", state.X, cpu.X)
+	check("Y", state.Y, cpu.Y)
+	check("DBR", state.DBR, cpu.DBR)
+	check("D", state.D, cpu.D)
+	check("PBR", state.PBR, cpu.K)
+	check("E", state.E, bit.BoolToUint8(cpu.eFlag))
+
+	mask := uint8(0xFF)
+	if cpu.eFlag {
+		mask = 0xCF
+	}
+	P := bit.BoolToUint8(cpu.cFlag)*0x01 +
+		bit.BoolToUint8(cpu.zFlag)*0x02 +
+		bit.BoolToUint8(cpu.iFlag)*0x04 +
+		bit.BoolToUint8(cpu.dFlag)*0x08 +
+		bit.BoolToUint8(cpu.xFlag)*0x10 +
+		bit.BoolToUint8(cpu.mFlag)*0x20 +
+		bit.BoolToUint8(cpu.vFlag)*0x40 +
+		bit.BoolToUint8(cpu.nFlag)*0x80
+	check("P", state.P&mask, P&mask)
+
+	for _, entry := range state.RAM {
+		check(fmt.Sprintf("RAM[%06X]", entry[0]), uint8(entry[1]), cpu.memory.GetByte(entry[0]))
+	}
+
+	return res
+}
+
+// run executes the test and returns the differences with the expected final state
+// The memory is shared between the tests, the RAM of the test is cleared once it is over
+func (test singleStepTest) run(mem *Memory) []string {
+	defer func() {
+		for _, entry := range append(test.Initial.RAM, test.Final.RAM...) {
+			mem.SetByte(0, entry[0])
+		}
+	}()
+
+	cpu := newCPU(mem, io.NewRegisterFactory())
+	test.Initial.load(cpu)
+
+	cpu.execOpcode()
+
+	res := test.Final.mismatches(cpu)
+	return append(res, test.cycleMismatches(mem.BusCycles())...)
+}
+
+// cycleMismatches compares the expected bus cycles with the recorded ones: type, address and value of the accesses
+func (test singleStepTest) cycleMismatches(cycles []BusCycle) []string {
+	var res []string
+	if len(cycles) != len(test.Cycles) {
+		res = append(res, fmt.Sprintf("cycles: expected %d, got %d", len(test.Cycles), len(cycles)))
+	}
+
+	for i := 0; i < len(cycles) && i < len(test.Cycles); i++ {
+		expected, err := parseSingleStepCycle(test.Cycles[i])
+		if err != nil {
+			return append(res, fmt.Sprintf("cycle %d: %v", i, err))
+		}
+
+		actual := cycles[i]
+		if expected.Type == BusInternal {
+			actual.Value = 0
+		}
+		actual.Cycles = 0
+		if expected != actual {
+			res = append(res, fmt.Sprintf("cycle %d: expected %s %06X (%02X), got %s %06X (%02X)",
+				i, expected.Type, expected.Address, expected.Value, actual.Type, actual.Address, actual.Value))
+		}
+	}
+	return res
+}
+
+// parseSingleStepCycle converts an (address, value, pins) entry of a test file to a bus cycle, without its length
+func parseSingleStepCycle(entry []interface{}) (BusCycle, error) {
+	if len(entry) != 3 {
+		return BusCycle{}, fmt.Errorf("invalid cycle %v", entry)
+	}
+	address, _ := entry[0].(float64)
+	value, _ := entry[1].(float64)
+	pins, ok := entry[2].(string)
+	if !ok || len(pins) < 4 {
+		return BusCycle{}, fmt.Errorf("invalid pins %v", entry[2])
+	}
+
+	cycle := BusCycle{Address: uint32(address), Type: BusInternal}
+	switch {
+	case pins[0] != 'd' && pins[1] != 'p':
+		return cycle, nil
+	case pins[3] == 'w':
+		cycle.Type = BusWrite
+	default:
+		cycle.Type = BusRead
+	}
+	cycle.Value = uint8(value)
+	return cycle, nil
+}
+
+func TestSingleStep(t *testing.T) {
+	files, err := singleStepFiles(*singleStepDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skipf("no single-step test files found in %s", *singleStepDir)
+	}
+	runSingleStepFiles(t, files)
+}
+
+func TestSingleStepRunner(t *testing.T) {
+	files, err := singleStepFiles("testdata/singlestep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, files)
+	runSingleStepFiles(t, files)
+
+	// The bus cycles are compared one by one
+	test := singleStepTest{Cycles: [][]interface{}{{4096.0, 234.0, "dp-r-mx-"}, {4097.0, nil, "---r-mx-"}}}
+	assert.Empty(t, test.cycleMismatches([]BusCycle{
+		{Address: 0x1000, Value: 0xEA, Type: BusRead, Cycles: 8},
+		{Address: 0x1001, Type: BusInternal, Cycles: 6},
+	}))
+	assert.Len(t, test.cycleMismatches([]BusCycle{
+		{Address: 0x1000, Value: 0xEA, Type: BusRead, Cycles: 8},
+		{Address: 0x1002, Type: BusInternal, Cycles: 6},
+	}), 1)
+	assert.Len(t, test.cycleMismatches([]BusCycle{
+		{Address: 0x1000, Value: 0xEB, Type: BusRead, Cycles: 8},
+		{Address: 0x1001, Value: 0xEB, Type: BusWrite, Cycles: 8},
+	}), 2)
+	assert.Len(t, test.cycleMismatches(nil), 1)
+}
+
+// singleStepFiles returns the test files found in the directory or its subdirectories, none if it doesn't exist
+func singleStepFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".json" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// runSingleStepFiles runs the tests of each file in a subtest named after the file
+func runSingleStepFiles(t *testing.T, files []string) {
+	mem := newFlatMemory()
+	mem.EnableBusTracer()
+	var passed, total int
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var tests []singleStepTest
+		if err := json.Unmarshal(buf, &tests); err != nil {
+			t.Fatalf("failed to parse %s: %v", file, err)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			failed := 0
+			for _, test := range tests {
+				res := test.run(mem)
+				if len(res) == 0 {
+					continue
+				}
+
+				if failed < maxReportedMismatches {
+					t.Errorf("%s:\n\t%s", test.Name, strings.Join(res, "\n\t"))
+				}
+				failed++
+			}
+
+			if failed > 0 {
+				t.Errorf("%d/%d tests failed", failed, len(tests))
+			}
+			passed += len(tests) - failed
+			total += len(tests)
+		})
+	}
+
+	t.Logf("%d/%d tests passed over %d test files", passed, total, len(files))
+}
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/snes-emu/gose/disasm"
+)
+
+// traceFormats format the state of the CPU before the execution of an instruction, one function per trace layout
+// The bsnes and mesen layouts follow the default trace logs of these emulators so that traces can be compared line by line
+// The H position of the bsnes and mesen layouts is the master cycle in the line, while the gose layout shows the H counter
+var traceFormats = map[string]func(cpu *CPU, K uint8, PC uint16) string{
+	"gose": func(cpu *CPU, K uint8, PC uint16) string {
+		return fmt.Sprintf("%s V:%3d H:%3d CYC:%d", cpu.stateLine(K, PC), cpu.ppu.VCounter(), cpu.ppu.HCounter(), cpu.elapsed)
+	},
+	"bsnes": func(cpu *CPU, K uint8, PC uint16) string {
+		i := cpu.disassemble()
+		text := strings.ToLower(i.Text())
+		if i.HasEffective {
+			text += fmt.Sprintf(" [%06x]", i.Effective)
+		}
+		return fmt.Sprintf("%02x%04x %-22s A:%04x X:%04x Y:%04x S:%04x D:%04x DB:%02x %s V:%3d H:%4d F:%2d",
+			K, PC, text, cpu.getCRegister(), cpu.getXRegister(), cpu.getYRegister(), cpu.getSRegister(), cpu.getDRegister(), cpu.getDBRRegister(),
+			cpu.prettyFlags()[1:], cpu.ppu.VCounter(), cpu.cycles, cpu.ppu.frame)
+	},
+	"mesen": func(cpu *CPU, K uint8, PC uint16) string {
+		i := cpu.disassemble()
+		text := i.Text()
+		if i.HasEffective {
+			text += fmt.Sprintf(" [$%06X]", i.Effective)
+		}
+		return fmt.Sprintf("%02X%04X  %-30s A:%04X X:%04X Y:%04X S:%04X D:%04X DB:%02X P:%s H:%-4d V:%-3d CYC:%d",
+			K, PC, text, cpu.getCRegister(), cpu.getXRegister(), cpu.getYRegister(), cpu.getSRegister(), cpu.getDRegister(), cpu.getDBRRegister(),
+			cpu.prettyFlags()[1:], cpu.cycles, cpu.ppu.VCounter(), cpu.elapsed)
+	},
+}
+
+// traceOptions select the layout of a trace and the instructions which are traced
+type traceOptions struct {
+	format string // key of traceFormats
+	ranges string // address range of the traced instructions (for instance 00:8000-00:FFFF), empty to trace all of them
+	frames string // range of frames during which instructions are traced (for instance 100-200 or 100-), empty to trace all of them
+}
+
+// traceLogger writes a line per executed instruction to a buffered writer
+type traceLogger struct {
+	sync.Mutex
+	w       *bufio.Writer // nil once the trace logger is closed
+	closers []io.Closer   // closed in order once the trace is over
+
+	format                func(cpu *CPU, K uint8, PC uint16) string
+	firstAddr, lastAddr   uint32
+	firstFrame, lastFrame uint64
+}
+
+// newTraceLogger creates a trace logger writing to w
+func newTraceLogger(w io.Writer, opts traceOptions) (*traceLogger, error) {
+	t := &traceLogger{
+		w:         bufio.NewWriterSize(w, 1<<16),
+		format:    traceFormats[opts.format],
+		lastAddr:  0xFFFFFF,
+		lastFrame: math.MaxUint64,
+	}
+	if t.format == nil {
+		return nil, fmt.Errorf("unknown trace format %q, possible values are gose, bsnes and mesen", opts.format)
+	}
+
+	if opts.ranges != "" {
+		first, last, err := parseRange(opts.ranges, func(s string) (uint64, error) {
+			addr, err := disasm.ParseAddress(s)
+			return uint64(addr), err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace address range: %w", err)
+		}
+		t.firstAddr, t.lastAddr = uint32(first), uint32(last)
+	}
+
+	if opts.frames != "" {
+		first, last, err := parseRange(opts.frames, func(s string) (uint64, error) {
+			return strconv.ParseUint(s, 10, 64)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace frame range: %w", err)
+		}
+		t.firstFrame, t.lastFrame = first, last
+	}
+
+	return t, nil
+}
+
+// openTraceLogger creates a trace logger writing to the given file, the trace is compressed with gzip if the file name ends with .gz
+func openTraceLogger(filename string, opts traceOptions) (*traceLogger, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer = f
+	closers := []io.Closer{f}
+	if strings.HasSuffix(filename, ".gz") {
+		gz := gzip.NewWriter(f)
+		w = gz
+		closers = []io.Closer{gz, f}
+	}
+
+	t, err := newTraceLogger(w, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t.closers = closers
+	return t, nil
+}
+
+// parseRange parses a range written as <first>-<last>, the last value is optional
+func parseRange(s string, parse func(string) (uint64, error)) (uint64, uint64, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected <first>-<last>, got %q", s)
+	}
+
+	first, err := parse(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	last := uint64(math.MaxUint64)
+	if parts[1] != "" {
+		if last, err = parse(parts[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if first > last {
+		return 0, 0, fmt.Errorf("empty range %q", s)
+	}
+	return first, last, nil
+}
+
+// log writes the state of the CPU before the execution of the instruction at K:PC, if it passes the filters
+func (t *traceLogger) log(cpu *CPU, K uint8, PC uint16) {
+	if addr := uint32(K)<<16 | uint32(PC); addr < t.firstAddr || addr > t.lastAddr {
+		return
+	}
+	if frame := cpu.ppu.frame; frame < t.firstFrame || frame > t.lastFrame {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if t.w == nil {
+		return
+	}
+	t.w.WriteString(t.format(cpu, K, PC))
+	t.w.WriteByte('\n')
+}
+
+// Close flushes the trace and closes the underlying file, nothing is written afterwards
+func (t *traceLogger) Close() error {
+	t.Lock()
+	defer t.Unlock()
+	if t.w == nil {
+		return nil
+	}
+
+	err := t.w.Flush()
+	t.w = nil
+	for _, c := range t.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestTraceCPU returns a CPU about to execute NOP / LDA $10 / BRA -4 from $00:0100
+func newTestTraceCPU() *CPU {
+	ppu := newTestCounterPPU()
+	cpu := ppu.cpu
+	for i, b := range []uint8{0xEA, 0xA5, 0x10, 0x80, 0xFB} {
+		cpu.memory.SetByteBank(b, 0x00, 0x0100+uint16(i))
+	}
+	cpu.PC = 0x0100
+	cpu.S = 0x01FF
+	return cpu
+}
+
+func TestTraceFormats(t *testing.T) {
+	for format, expected := range map[string][]string{
+		"gose": {
+			"$00:0100 NOP                      A:0000 X:0000 Y:0000 D:0000 DB:00 S:01FF P:envmxdizc V:  0 H:  0 CYC:0",
+			"$00:0101 LDA $10 ; $000010        A:0000 X:0000 Y:0000 D:0000 DB:00 S:01FF P:envmxdizc V:  0 H:  3 CYC:14",
+			"$00:0103 BRA $0100                A:0000 X:0000 Y:0000 D:0000 DB:00 S:01FF P:envmxdiZc V:  0 H: 11 CYC:46",
+		},
+		"bsnes": {
+			"000100 nop                    A:0000 X:0000 Y:0000 S:01ff D:0000 DB:00 nvmxdizc V:  0 H:   0 F: 0",
+			"000101 lda $10 [000010]       A:0000 X:0000 Y:0000 S:01ff D:0000 DB:00 nvmxdizc V:  0 H:  14 F: 0",
+			"000103 bra $0100              A:0000 X:0000 Y:0000 S:01ff D:0000 DB:00 nvmxdiZc V:  0 H:  46 F: 0",
+		},
+		"mesen": {
+			"000100  NOP                            A:0000 X:0000 Y:0000 S:01FF D:0000 DB:00 P:nvmxdizc H:0    V:0   CYC:0",
+			"000101  LDA $10 [$000010]              A:0000 X:0000 Y:0000 S:01FF D:0000 DB:00 P:nvmxdizc H:14   V:0   CYC:14",
+			"000103  BRA $0100                      A:0000 X:0000 Y:0000 S:01FF D:0000 DB:00 P:nvmxdiZc H:46   V:0   CYC:46",
+		},
+	} {
+		cpu := newTestTraceCPU()
+		var buf bytes.Buffer
+		trace, err := newTraceLogger(&buf, traceOptions{format: format})
+		assert.NoError(t, err)
+		cpu.trace = trace
+
+		// the NOP lasts 14 master cycles and the 16-bit LDA from the low WRAM 32 master cycles
+		for range expected {
+			cpu.execOpcode()
+		}
+		assert.NoError(t, trace.Close())
+		assert.Equal(t, strings.Join(expected, "\n")+"\n", buf.String(), format)
+
+		// nothing is written once the trace is closed
+		cpu.execOpcode()
+		assert.Equal(t, strings.Join(expected, "\n")+"\n", buf.String(), format)
+	}
+
+	_, err := newTraceLogger(&bytes.Buffer{}, traceOptions{format: "snes9x"})
+	assert.Error(t, err)
+}
+
+func TestTraceFilters(t *testing.T) {
+	// only the instructions from $00:0101 to $00:0102 of the frames 1 and 2 are traced
+	cpu := newTestTraceCPU()
+	var buf bytes.Buffer
+	trace, err := newTraceLogger(&buf, traceOptions{format: "gose", ranges: "00:0101-00:0102", frames: "1-2"})
+	assert.NoError(t, err)
+	cpu.trace = trace
+
+	for cpu.ppu.frame < 4 {
+		cpu.execOpcode()
+	}
+	assert.NoError(t, trace.Close())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.True(t, len(lines) > 100)
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "$00:0101 LDA $10"), line)
+	}
+
+	for _, opts := range []traceOptions{
+		{format: "gose", ranges: "00:0101"},
+		{format: "gose", ranges: "00:0102-00:0101"},
+		{format: "gose", ranges: "00:01G1-"},
+		{format: "gose", frames: "-2"},
+	} {
+		_, err := newTraceLogger(&bytes.Buffer{}, opts)
+		assert.Error(t, err, "%+v", opts)
+	}
+}
+
+func TestTraceCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gose-trace")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cpu := newTestTraceCPU()
+	filename := filepath.Join(dir, "trace.log.gz")
+	trace, err := openTraceLogger(filename, traceOptions{format: "gose"})
+	assert.NoError(t, err)
+	cpu.trace = trace
+	cpu.execOpcode()
+	assert.NoError(t, trace.Close())
+
+	f, err := os.Open(filename)
+	assert.NoError(t, err)
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	buf, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf), "$00:0100 NOP"), string(buf))
+}
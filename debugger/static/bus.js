@@ -0,0 +1,23 @@
+import { DynamicTable } from "./table.js";
+
+class Bus extends DynamicTable {
+    static tagName() {
+        return 'bus-table';
+    }
+
+    constructor() {
+        super();
+        this.id = "bus";
+    }
+
+    // Rows are prepended: add the cycles in reverse order so the last instruction reads from top to bottom
+    addCycles(cycles) {
+        [...cycles].reverse().forEach(cycle => this.addEntry(cycle));
+    }
+}
+
+customElements.define(Bus.tagName(), Bus, {extends: 'table'});
+
+export function newBus() {
+    return document.createElement('table', {is: Bus.tagName()})
+}
@@ -2,6 +2,7 @@ import { newPalette } from "./palette.js";
 import { newCPU } from "./cpu.js";
 import { newSprites } from "./sprites.js";
 import { newRegister } from "./register.js";
+import { newBus } from "./bus.js";
 import { newTabManager } from "./tab_manager.js";
 
 
@@ -10,6 +11,7 @@ const cpuTab = newCPU();
 const paletteTab = newPalette();
 const spritesTab = newSprites();
 const registerTab = newRegister();
+const busTab = newBus();
 
 const tabManager = newTabManager();
 tabManager.setTabs([
@@ -28,6 +30,10 @@ tabManager.setTabs([
     {
         "name": "Registers",
         "component": registerTab,
+    },
+    {
+        "name": "Bus",
+        "component": busTab,
     }
 ]);
 
@@ -78,4 +84,7 @@ function displayState(body) {
     if (body.register) {
         registerTab.addData(body.register);
     }
+    if (body.bus) {
+        busTab.addCycles(body.bus);
+    }
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/snes-emu/gose/core"
+	"github.com/snes-emu/gose/disasm"
+	"github.com/snes-emu/gose/rom"
+)
+
+// romMemory exposes the content of a ROM at the addresses it is mapped to, other addresses read as 0
+type romMemory struct {
+	*rom.ROM
+}
+
+func (m romMemory) Peek(addr uint32) uint8 {
+	if pos, ok := m.Offset(addr); ok {
+		return m.Data[pos]
+	}
+	return 0
+}
+
+// entryFlags is a list of code entry points given on the command line
+type entryFlags []disasm.Entry
+
+func (e *entryFlags) String() string {
+	return fmt.Sprint(*e)
+}
+
+func (e *entryFlags) Set(value string) error {
+	addr, err := disasm.ParseAddress(value)
+	if err != nil {
+		return err
+	}
+	*e = append(*e, disasm.Entry{Address: addr})
+	return nil
+}
+
+// tableFlags is a list of pointer tables given on the command line as <address>,<count>
+type tableFlags struct {
+	tables *[]disasm.Table
+	long   bool
+}
+
+func (t tableFlags) String() string {
+	if t.tables == nil {
+		return ""
+	}
+	return fmt.Sprint(*t.tables)
+}
+
+func (t tableFlags) Set(value string) error {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return fmt.Errorf("expected <address>,<count>, got %q", value)
+	}
+	addr, err := disasm.ParseAddress(parts[0])
+	if err != nil {
+		return err
+	}
+	count, err := strconv.Atoi(parts[1])
+	if err != nil || count <= 0 {
+		return fmt.Errorf("invalid pointer count %q", parts[1])
+	}
+
+	*t.tables = append(*t.tables, disasm.Table{Address: addr, Count: count, Long: t.long})
+	return nil
+}
+
+// runDisasm runs the disasm command: it follows the code of a ROM from its interrupt vectors and writes an annotated listing
+func runDisasm(args []string) error {
+	var (
+		entries entryFlags
+		tables  []disasm.Table
+	)
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	output := flags.String("o", "", "file the listing is written to (default stdout)")
+	symbolFile := flags.String("symbols", "", "symbol file naming the labels (WLA-DX format: one \"BB:AAAA name\" per line)")
+	flags.Var(&entries, "entry", "address of additional code to follow, can be repeated")
+	flags.Var(tableFlags{tables: &tables}, "table", "table of 16-bit code pointers given as <address>,<count>, can be repeated")
+	flags.Var(tableFlags{tables: &tables, long: true}, "long-table", "table of 24-bit code pointers given as <address>,<count>, can be repeated")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gose disasm [flags] <rom file>")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("please provide a rom file to disassemble")
+	}
+
+	r, err := core.ReadROMFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var symbols map[uint32]string
+	if *symbolFile != "" {
+		f, err := os.Open(*symbolFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if symbols, err = disasm.ReadSymbols(f); err != nil {
+			return fmt.Errorf("failed to read %s: %w", *symbolFile, err)
+		}
+	}
+
+	mem := romMemory{r}
+	program := disasm.Analyze(mem, append(disasm.Vectors(mem), entries...), tables, symbols)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	fmt.Fprintf(w, "; %s\n; %d instructions\n\n", strings.TrimSpace(r.Title), len(program.Instructions))
+	return program.WriteListing(w)
+}
@@ -0,0 +1,302 @@
+package disasm
+
+import "fmt"
+
+// width is what is known of the M or X flag while following the code
+type width uint8
+
+const (
+	unknownWidth width = iota
+	narrow             // the flag is set, the register is 8-bit wide
+	wide               // the flag is cleared, the register is 16-bit wide
+)
+
+// flags is what is known of the M and X flags at a given address
+type flags struct {
+	m, x width
+}
+
+// known returns whether the width of every register is known
+func (f flags) known() bool {
+	return f.m != unknownWidth && f.x != unknownWidth
+}
+
+// context returns the context used to decode an instruction with the given opcode
+func (f flags) context(opcode uint8) Context {
+	ctx := Context{M: f.m != wide, X: f.x != wide}
+	switch Opcodes[opcode].Mode {
+	case ImmediateM:
+		ctx.Guess = f.m == unknownWidth
+	case ImmediateX:
+		ctx.Guess = f.x == unknownWidth
+	}
+	return ctx
+}
+
+// Entry is an address from which the code is followed
+type Entry struct {
+	Address uint32
+	Name    string // name of the label of the entry, a default one is used if empty
+
+	// Emulation is set when the code is entered in emulation mode, where the registers are 8-bit wide
+	// Otherwise the width of the registers is unknown until a REP or SEP instruction is met
+	Emulation bool
+}
+
+// vectors are the interrupt vectors of the 65C816 in bank $00
+var vectors = []struct {
+	addr      uint32
+	name      string
+	emulation bool
+}{
+	{0xFFFC, "reset", true},
+	{0xFFEA, "nmi", false},
+	{0xFFEE, "irq", false},
+	{0xFFE6, "brk", false},
+	{0xFFE4, "cop", false},
+	{0xFFFA, "emu_nmi", true},
+	{0xFFFE, "emu_irq", true},
+	{0xFFF4, "emu_cop", true},
+}
+
+// Vectors returns the entries pointed by the interrupt vectors, the reset vector comes first
+// Unused vectors ($0000 or $FFFF) are skipped
+func Vectors(mem Memory) []Entry {
+	var res []Entry
+	for _, v := range vectors {
+		addr := peekWord(mem, v.addr)
+		if addr == 0x0000 || addr == 0xFFFF {
+			continue
+		}
+		res = append(res, Entry{Address: uint32(addr), Name: v.name, Emulation: v.emulation})
+	}
+	return res
+}
+
+// Table is a table of code pointers, typically used by an indirect jump
+type Table struct {
+	Address uint32
+	Count   int  // number of pointers in the table
+	Long    bool // the pointers are 24-bit, otherwise they are 16-bit and point in the bank of the table
+}
+
+// Size returns the size of the table in bytes
+func (t Table) Size() uint32 {
+	if t.Long {
+		return 3 * uint32(t.Count)
+	}
+	return 2 * uint32(t.Count)
+}
+
+// Pointers returns the addresses pointed by the table
+func (t Table) Pointers(mem Memory) []uint32 {
+	res := make([]uint32, t.Count)
+	for n := range res {
+		if t.Long {
+			res[n] = peekLong(mem, next(t.Address, 3*uint32(n)))
+		} else {
+			res[n] = t.Address&0xFF0000 | uint32(peekWord(mem, next(t.Address, 2*uint32(n))))
+		}
+	}
+	return res
+}
+
+// Program is the result of the analysis of the code of a ROM
+type Program struct {
+	Instructions map[uint32]Instruction
+	Tables       map[uint32]Table
+	Labels       map[uint32]string
+	XRefs        map[uint32][]uint32 // addresses of the instructions and tables referencing an address
+
+	// Guessed holds the addresses of the instructions decoded with an unknown register width
+	Guessed map[uint32]bool
+
+	mem Memory
+}
+
+// pending is an address left to follow
+type pending struct {
+	addr  uint32
+	flags flags
+}
+
+// analyzer follows the code from the entries of a program
+type analyzer struct {
+	mem     Memory
+	program *Program
+	queue   []pending
+}
+
+// Analyze follows the code from the given entries and the pointers of the given tables
+// Branches, jumps and subroutine calls with a constant target are followed, indirect jumps are only followed
+// through the given tables. The width of the registers is tracked through REP, SEP, PLP and XCE instructions.
+// Symbols give the name of the labels, unnamed targets get a default label.
+func Analyze(mem Memory, entries []Entry, tables []Table, symbols map[uint32]string) *Program {
+	a := &analyzer{
+		mem: mem,
+		program: &Program{
+			Instructions: make(map[uint32]Instruction),
+			Tables:       make(map[uint32]Table),
+			Labels:       make(map[uint32]string),
+			XRefs:        make(map[uint32][]uint32),
+			Guessed:      make(map[uint32]bool),
+			mem:          mem,
+		},
+	}
+	for addr, name := range symbols {
+		a.program.Labels[addr] = name
+	}
+
+	for _, e := range entries {
+		f := flags{}
+		if e.Emulation {
+			f = flags{narrow, narrow}
+		}
+		name := e.Name
+		if name == "" {
+			name = fmt.Sprintf("loc_%06X", e.Address)
+		}
+		a.label(e.Address, name)
+		a.queue = append(a.queue, pending{e.Address, f})
+	}
+
+	for _, t := range tables {
+		a.program.Tables[t.Address] = t
+		a.label(t.Address, fmt.Sprintf("tbl_%06X", t.Address))
+		for _, ptr := range t.Pointers(mem) {
+			a.reference(t.Address, ptr, fmt.Sprintf("loc_%06X", ptr))
+			a.queue = append(a.queue, pending{ptr, flags{}})
+		}
+	}
+
+	for len(a.queue) > 0 {
+		p := a.pop()
+		a.follow(p.addr, p.flags)
+	}
+
+	return a.program
+}
+
+// pop returns the next address to follow, addresses with known register widths are followed first
+// so that code reached from several places is decoded with the most accurate widths
+func (a *analyzer) pop() pending {
+	idx := 0
+	for n, p := range a.queue {
+		if p.flags.known() {
+			idx = n
+			break
+		}
+	}
+
+	p := a.queue[idx]
+	a.queue = append(a.queue[:idx], a.queue[idx+1:]...)
+	return p
+}
+
+// label names an address if it is not named yet
+func (a *analyzer) label(addr uint32, name string) {
+	if _, ok := a.program.Labels[addr]; !ok && name != "" {
+		a.program.Labels[addr] = name
+	}
+}
+
+// reference records a reference from one address to another, which is labeled with the given name if unnamed
+func (a *analyzer) reference(from, to uint32, name string) {
+	a.program.XRefs[to] = append(a.program.XRefs[to], from)
+	a.label(to, name)
+}
+
+// follow decodes the instructions from addr until the flow of the code stops or reaches already decoded code
+func (a *analyzer) follow(addr uint32, f flags) {
+	for {
+		if _, ok := a.program.Instructions[addr]; ok {
+			return
+		}
+		if _, ok := a.program.Tables[addr]; ok {
+			return
+		}
+
+		opcode := a.mem.Peek(addr)
+		ctx := f.context(opcode)
+		i := Decode(a.mem, addr, ctx)
+		a.program.Instructions[addr] = i
+		if ctx.Guess {
+			a.program.Guessed[addr] = true
+		}
+
+		f = f.update(i)
+
+		if target, ok := i.Target(); ok {
+			name := fmt.Sprintf("loc_%06X", target)
+			if i.Mnemonic == "JSR" || i.Mnemonic == "JSL" {
+				name = fmt.Sprintf("sub_%06X", target)
+			}
+			a.reference(addr, target, name)
+			a.queue = append(a.queue, pending{target, f})
+		} else if i.Mode == AbsoluteXIndirect {
+			// the pointers of a jump table are in the program bank
+			a.followTable(addr, addr&0xFF0000|i.value(), f)
+		}
+
+		if !continues(i) {
+			return
+		}
+		addr = next(addr, i.Size())
+	}
+}
+
+// followTable follows the pointers of the table used by the indirect jump at addr, if the table is known
+func (a *analyzer) followTable(addr uint32, table uint32, f flags) {
+	t, ok := a.program.Tables[table]
+	if !ok {
+		return
+	}
+
+	a.program.XRefs[table] = append(a.program.XRefs[table], addr)
+	for _, ptr := range t.Pointers(a.mem) {
+		a.queue = append(a.queue, pending{ptr, f})
+	}
+}
+
+// update returns the flags after the execution of the instruction
+func (f flags) update(i Instruction) flags {
+	switch i.Mnemonic {
+	case "REP":
+		if i.Operand[0]&0x20 != 0 {
+			f.m = wide
+		}
+		if i.Operand[0]&0x10 != 0 {
+			f.x = wide
+		}
+	case "SEP":
+		if i.Operand[0]&0x20 != 0 {
+			f.m = narrow
+		}
+		if i.Operand[0]&0x10 != 0 {
+			f.x = narrow
+		}
+	case "PLP":
+		f = flags{}
+	case "XCE":
+		// entering the native mode keeps the registers 8-bit wide, entering the emulation mode makes them 8-bit wide
+		// so the width is only known if the registers were already 8-bit wide
+		if f.m == wide {
+			f.m = unknownWidth
+		}
+		if f.x == wide {
+			f.x = unknownWidth
+		}
+	}
+	return f
+}
+
+// continues returns whether the execution continues with the next instruction once the instruction is executed
+// Subroutines are assumed to return and to preserve the width of the registers
+func continues(i Instruction) bool {
+	switch i.Mnemonic {
+	case "JMP", "BRA", "BRL", "RTS", "RTL", "RTI", "STP", "BRK", "COP":
+		return false
+	default:
+		return true
+	}
+}
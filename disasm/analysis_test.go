@@ -0,0 +1,101 @@
+package disasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	mem := testMemory{}
+	mem.write(0x00FFFC, 0x00, 0x80)                   // reset vector
+	mem.write(0x00FFEA, 0x40, 0x80)                   // native NMI vector
+	mem.write(0x008000, 0x18, 0xFB, 0xA9, 0x01)       // CLC / XCE / LDA #$01
+	mem.write(0x008004, 0xC2, 0x30, 0xA9, 0x34, 0x12) // REP #$30 / LDA #$1234
+	mem.write(0x008009, 0x20, 0x20, 0x80)             // JSR $8020
+	mem.write(0x00800C, 0xFC, 0x30, 0x80)             // JSR ($8030,X)
+	mem.write(0x00800F, 0x80, 0xFE)                   // BRA $800F
+	mem.write(0x008020, 0xF0, 0x01, 0xE8, 0x60)       // BEQ $8023 / INX / RTS
+	mem.write(0x008030, 0x50, 0x80)                   // table of pointers
+	mem.write(0x008040, 0xA9, 0x00, 0x00, 0x40)       // LDA #$0000 / RTI
+	mem.write(0x008050, 0xA2, 0x01, 0x00, 0x60)       // LDX #$0001 / RTS
+
+	symbols := map[uint32]string{0x008020: "Init"}
+	p := Analyze(mem, Vectors(mem), []Table{{Address: 0x008030, Count: 1}}, symbols)
+
+	// the width of the registers is tracked from the reset
+	assert.Equal(t, "LDA #$01", p.Instructions[0x008002].String())
+	assert.Equal(t, "LDA #$1234", p.Instructions[0x008006].String())
+
+	// the table pointers are followed with the width of the registers at the jump
+	assert.Equal(t, "LDX #$0001", p.Instructions[0x008050].String())
+	assert.False(t, p.Guessed[0x008050])
+
+	// the width is unknown in the NMI handler
+	assert.Equal(t, "LDA #$0000", p.Instructions[0x008040].String())
+	assert.True(t, p.Guessed[0x008040])
+
+	// the flow stops after unconditional jumps and returns
+	assert.Contains(t, p.Instructions, uint32(0x008022))
+	assert.Contains(t, p.Instructions, uint32(0x008023))
+	assert.NotContains(t, p.Instructions, uint32(0x008011))
+	assert.NotContains(t, p.Instructions, uint32(0x008024))
+	assert.Len(t, p.Instructions, 15)
+
+	assert.Equal(t, map[uint32]string{
+		0x008000: "reset",
+		0x008040: "nmi",
+		0x008020: "Init",
+		0x008023: "loc_008023",
+		0x008030: "tbl_008030",
+		0x008050: "loc_008050",
+		0x00800F: "loc_00800F",
+	}, p.Labels)
+	assert.Equal(t, []uint32{0x008009}, p.XRefs[0x008020])
+	assert.Equal(t, []uint32{0x00800C}, p.XRefs[0x008030])
+	assert.Equal(t, []uint32{0x008030}, p.XRefs[0x008050])
+}
+
+func TestWriteListing(t *testing.T) {
+	mem := testMemory{}
+	mem.write(0x008000, 0x20, 0x10, 0x80, 0x60) // JSR $8010 / RTS
+	mem.write(0x008010, 0x60)                   // RTS
+
+	var buf bytes.Buffer
+	p := Analyze(mem, []Entry{{Address: 0x008000, Name: "main", Emulation: true}}, nil, nil)
+	assert.NoError(t, p.WriteListing(&buf))
+	assert.Equal(t, strings.Join([]string{
+		"main:",
+		"$00:8000  20 10 80     JSR sub_008010",
+		"$00:8003  60           RTS",
+		"",
+		"sub_008010:                    ; xref: $00:8000",
+		"$00:8010  60           RTS",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestReadSymbols(t *testing.T) {
+	symbols, err := ReadSymbols(strings.NewReader(`
+; WLA-DX symbol file
+[labels]
+00:8000 Reset
+7e:0010 frameCounter ; comment
+$C08000 Data
+[definitions]
+00000001 _sizeof_Reset
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint32]string{
+		0x008000: "Reset",
+		0x7E0010: "frameCounter",
+		0xC08000: "Data",
+	}, symbols)
+
+	_, err = ReadSymbols(strings.NewReader("00:80000 Reset"))
+	assert.Error(t, err)
+	_, err = ReadSymbols(strings.NewReader("00:8000"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,337 @@
+// Package disasm decodes the instructions of the 65C816
+package disasm
+
+import (
+	"fmt"
+
+	"github.com/snes-emu/gose/bit"
+)
+
+// Mode is the addressing mode of an instruction, it defines the size of the operand and how it is used
+type Mode uint8
+
+// Addressing modes of the 65C816
+// See: https://wiki.superfamicom.org/65816-reference#addressing-modes
+const (
+	Implied                Mode = iota // no operand
+	Accumulator                        // A
+	ImmediateM                         // #$12 or #$1234 depending on the M flag
+	ImmediateX                         // #$12 or #$1234 depending on the X flag
+	Immediate8                         // #$12
+	Immediate16                        // $1234 (PEA)
+	Direct                             // $12
+	DirectX                            // $12,X
+	DirectY                            // $12,Y
+	DirectIndirect                     // ($12)
+	DirectIndirectLong                 // [$12]
+	DirectXIndirect                    // ($12,X)
+	DirectIndirectY                    // ($12),Y
+	DirectIndirectLongY                // [$12],Y
+	Absolute                           // $1234
+	AbsoluteX                          // $1234,X
+	AbsoluteY                          // $1234,Y
+	AbsoluteLong                       // $123456
+	AbsoluteLongX                      // $123456,X
+	AbsoluteIndirect                   // ($1234)
+	AbsoluteXIndirect                  // ($1234,X)
+	AbsoluteIndirectLong               // [$1234]
+	StackRelative                      // $12,S
+	StackRelativeIndirectY             // ($12,S),Y
+	Relative                           // 8-bit signed offset, displayed as the target address
+	RelativeLong                       // 16-bit signed offset, displayed as the target address
+	BlockMove                          // source bank, destination bank
+)
+
+// operandSizes holds the operand size in bytes of the addressing modes with a fixed size operand
+var operandSizes = map[Mode]uint32{
+	Immediate8: 1, Immediate16: 2,
+	Direct: 1, DirectX: 1, DirectY: 1, DirectIndirect: 1, DirectIndirectLong: 1, DirectXIndirect: 1, DirectIndirectY: 1, DirectIndirectLongY: 1,
+	Absolute: 2, AbsoluteX: 2, AbsoluteY: 2, AbsoluteLong: 3, AbsoluteLongX: 3,
+	AbsoluteIndirect: 2, AbsoluteXIndirect: 2, AbsoluteIndirectLong: 2,
+	StackRelative: 1, StackRelativeIndirectY: 1,
+	Relative: 1, RelativeLong: 2, BlockMove: 2,
+}
+
+// Memory is read to decode the instructions, reads must not have any side effect
+type Memory interface {
+	Peek(addr uint32) uint8
+}
+
+// Registers holds the CPU registers used to compute effective addresses
+type Registers struct {
+	D   uint16 // direct page register
+	DBR uint8  // data bank register
+	S   uint16 // stack pointer
+	X   uint16
+	Y   uint16
+}
+
+// Context describes the CPU state in which an instruction is decoded
+type Context struct {
+	M, X bool // accumulator and index registers are 8-bit wide (M and X flags)
+
+	// Guess is set when the M and X flags are unknown, in which case the size of immediates is guessed (see guessWide)
+	Guess bool
+
+	// Registers are used to compute the effective address of the instruction (nil if unknown)
+	Registers *Registers
+}
+
+// Instruction is a decoded instruction
+type Instruction struct {
+	Address  uint32
+	Opcode   uint8
+	Mnemonic string
+	Mode     Mode
+	Operand  []uint8 // operand bytes (little endian)
+
+	// Effective address accessed by the instruction, only set if the registers were given
+	Effective    uint32
+	HasEffective bool
+}
+
+// Size returns the size of the instruction in bytes
+func (i Instruction) Size() uint32 {
+	return 1 + uint32(len(i.Operand))
+}
+
+// value returns the operand as a little endian value
+func (i Instruction) value() uint32 {
+	var v uint32
+	for n, b := range i.Operand {
+		v |= uint32(b) << (8 * uint(n))
+	}
+	return v
+}
+
+// relativeTarget returns the address targeted by the relative offset of the instruction, in the bank of the instruction
+func (i Instruction) relativeTarget() uint32 {
+	offset := uint16(i.value())
+	if i.Mode == Relative {
+		offset = uint16(int8(i.Operand[0]))
+	}
+	return i.Address&0xFF0000 | uint32(uint16(i.Address+i.Size())+offset)
+}
+
+// Target returns the address the instruction jumps to, for branches and jumps with a constant target
+func (i Instruction) Target() (uint32, bool) {
+	bank := i.Address & 0xFF0000
+
+	switch i.Mode {
+	case Relative:
+		return i.relativeTarget(), true
+	case RelativeLong:
+		// PER pushes the target address instead of jumping to it
+		if i.Mnemonic == "PER" {
+			return 0, false
+		}
+		return i.relativeTarget(), true
+	case Absolute:
+		if i.Mnemonic == "JMP" || i.Mnemonic == "JSR" {
+			return bank | i.value(), true
+		}
+	case AbsoluteLong:
+		if i.Mnemonic == "JMP" || i.Mnemonic == "JSL" {
+			return i.value(), true
+		}
+	}
+
+	return 0, false
+}
+
+// Operands returns the operands in assembly syntax
+func (i Instruction) Operands() string {
+	v := i.value()
+	switch i.Mode {
+	case Accumulator:
+		return "A"
+	case ImmediateM, ImmediateX, Immediate8:
+		if len(i.Operand) == 2 {
+			return fmt.Sprintf("#$%04X", v)
+		}
+		return fmt.Sprintf("#$%02X", v)
+	case Immediate16, Absolute:
+		return fmt.Sprintf("$%04X", v)
+	case Direct:
+		return fmt.Sprintf("$%02X", v)
+	case DirectX:
+		return fmt.Sprintf("$%02X,X", v)
+	case DirectY:
+		return fmt.Sprintf("$%02X,Y", v)
+	case DirectIndirect:
+		return fmt.Sprintf("($%02X)", v)
+	case DirectIndirectLong:
+		return fmt.Sprintf("[$%02X]", v)
+	case DirectXIndirect:
+		return fmt.Sprintf("($%02X,X)", v)
+	case DirectIndirectY:
+		return fmt.Sprintf("($%02X),Y", v)
+	case DirectIndirectLongY:
+		return fmt.Sprintf("[$%02X],Y", v)
+	case AbsoluteX:
+		return fmt.Sprintf("$%04X,X", v)
+	case AbsoluteY:
+		return fmt.Sprintf("$%04X,Y", v)
+	case AbsoluteLong:
+		return fmt.Sprintf("$%06X", v)
+	case AbsoluteLongX:
+		return fmt.Sprintf("$%06X,X", v)
+	case AbsoluteIndirect:
+		return fmt.Sprintf("($%04X)", v)
+	case AbsoluteXIndirect:
+		return fmt.Sprintf("($%04X,X)", v)
+	case AbsoluteIndirectLong:
+		return fmt.Sprintf("[$%04X]", v)
+	case StackRelative:
+		return fmt.Sprintf("$%02X,S", v)
+	case StackRelativeIndirectY:
+		return fmt.Sprintf("($%02X,S),Y", v)
+	case Relative, RelativeLong:
+		return fmt.Sprintf("$%04X", uint16(i.relativeTarget()))
+	case BlockMove:
+		// the destination bank comes first in the machine code
+		return fmt.Sprintf("$%02X,$%02X", i.Operand[1], i.Operand[0])
+	default:
+		return ""
+	}
+}
+
+// Text returns the instruction in assembly syntax
+func (i Instruction) Text() string {
+	if operands := i.Operands(); operands != "" {
+		return i.Mnemonic + " " + operands
+	}
+	return i.Mnemonic
+}
+
+// String returns the instruction in assembly syntax, followed by the effective address in a comment if known
+func (i Instruction) String() string {
+	res := i.Text()
+	if i.HasEffective {
+		res += fmt.Sprintf(" ; $%06X", i.Effective)
+	}
+	return res
+}
+
+// Decode decodes the instruction at the given address
+func Decode(mem Memory, addr uint32, ctx Context) Instruction {
+	opcode := mem.Peek(addr)
+	op := Opcodes[opcode]
+
+	size := operandSizes[op.Mode]
+	if op.Mode == ImmediateM || op.Mode == ImmediateX {
+		wide := !ctx.M
+		if op.Mode == ImmediateX {
+			wide = !ctx.X
+		}
+		if ctx.Guess {
+			wide = guessWide(mem, addr)
+		}
+
+		size = 1
+		if wide {
+			size = 2
+		}
+	}
+
+	i := Instruction{
+		Address:  addr,
+		Opcode:   opcode,
+		Mnemonic: op.Mnemonic,
+		Mode:     op.Mode,
+		Operand:  make([]uint8, size),
+	}
+	for n := range i.Operand {
+		i.Operand[n] = mem.Peek(next(addr, uint32(n)+1))
+	}
+
+	if ctx.Registers != nil {
+		i.Effective, i.HasEffective = effectiveAddress(mem, i, *ctx.Registers)
+	}
+
+	return i
+}
+
+// next returns the address of the byte at the given offset of addr in the same bank, the program counter wraps around the bank
+func next(addr uint32, offset uint32) uint32 {
+	return addr&0xFF0000 | (addr+offset)&0xFFFF
+}
+
+// unlikelyOpcodes are opcodes which are hardly ever used in actual code
+var unlikelyOpcodes = map[uint8]bool{
+	0x00: true, // BRK
+	0x02: true, // COP
+	0x42: true, // WDM
+	0xDB: true, // STP
+	0xFF: true, // SBC long,X
+}
+
+// guessWide guesses if the immediate of the instruction at addr is 16-bit when the M or X flag is unknown
+// The immediate is considered 16-bit if the next instruction would be an unlikely one with an 8-bit immediate but not with a 16-bit one
+func guessWide(mem Memory, addr uint32) bool {
+	return unlikelyOpcodes[mem.Peek(next(addr, 2))] && !unlikelyOpcodes[mem.Peek(next(addr, 3))]
+}
+
+// peekWord reads a 16-bit little endian value, the address wraps around the bank
+func peekWord(mem Memory, addr uint32) uint16 {
+	return bit.JoinUint16(mem.Peek(addr), mem.Peek(next(addr, 1)))
+}
+
+// peekLong reads a 24-bit little endian value, the address wraps around the bank
+func peekLong(mem Memory, addr uint32) uint32 {
+	return bit.JoinUint32(mem.Peek(addr), mem.Peek(next(addr, 1)), mem.Peek(next(addr, 2)))
+}
+
+// effectiveAddress returns the address of the data accessed by the instruction
+func effectiveAddress(mem Memory, i Instruction, r Registers) (uint32, bool) {
+	v := i.value()
+	data := uint32(r.DBR) << 16
+	program := i.Address & 0xFF0000
+	direct := func(offset uint16) uint32 {
+		return uint32(r.D + uint16(v) + offset)
+	}
+
+	switch i.Mode {
+	case Direct:
+		return direct(0), true
+	case DirectX:
+		return direct(r.X), true
+	case DirectY:
+		return direct(r.Y), true
+	case DirectIndirect:
+		return data | uint32(peekWord(mem, direct(0))), true
+	case DirectIndirectLong:
+		return peekLong(mem, direct(0)), true
+	case DirectXIndirect:
+		return data | uint32(peekWord(mem, direct(r.X))), true
+	case DirectIndirectY:
+		return ((data | uint32(peekWord(mem, direct(0)))) + uint32(r.Y)) & 0xFFFFFF, true
+	case DirectIndirectLongY:
+		return (peekLong(mem, direct(0)) + uint32(r.Y)) & 0xFFFFFF, true
+	case Absolute:
+		if i.Mnemonic == "JMP" || i.Mnemonic == "JSR" {
+			return program | v, true
+		}
+		return data | v, true
+	case AbsoluteX:
+		return ((data | v) + uint32(r.X)) & 0xFFFFFF, true
+	case AbsoluteY:
+		return ((data | v) + uint32(r.Y)) & 0xFFFFFF, true
+	case AbsoluteLong:
+		return v, true
+	case AbsoluteLongX:
+		return (v + uint32(r.X)) & 0xFFFFFF, true
+	case AbsoluteIndirect:
+		return program | uint32(peekWord(mem, v)), true
+	case AbsoluteXIndirect:
+		return program | uint32(peekWord(mem, program|uint32(uint16(v)+r.X))), true
+	case AbsoluteIndirectLong:
+		return peekLong(mem, v), true
+	case StackRelative:
+		return uint32(r.S + uint16(v)), true
+	case StackRelativeIndirectY:
+		return ((data | uint32(peekWord(mem, uint32(r.S+uint16(v))))) + uint32(r.Y)) & 0xFFFFFF, true
+	default:
+		return 0, false
+	}
+}
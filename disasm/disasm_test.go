@@ -0,0 +1,120 @@
+package disasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMemory is a sparse memory, unset bytes are 0
+type testMemory map[uint32]uint8
+
+func (m testMemory) Peek(addr uint32) uint8 {
+	return m[addr]
+}
+
+// write writes the given bytes from addr
+func (m testMemory) write(addr uint32, bytes ...uint8) {
+	for i, b := range bytes {
+		m[addr+uint32(i)] = b
+	}
+}
+
+func TestDecodeImmediate(t *testing.T) {
+	mem := testMemory{}
+	mem.write(0x008000, 0xA9, 0x34, 0x12, 0xEA) // LDA #$1234 / NOP
+
+	i := Decode(mem, 0x008000, Context{M: true})
+	assert.Equal(t, "LDA #$34", i.String())
+	assert.EqualValues(t, 2, i.Size())
+
+	i = Decode(mem, 0x008000, Context{M: false})
+	assert.Equal(t, "LDA #$1234", i.String())
+	assert.EqualValues(t, 3, i.Size())
+
+	// The X flag is used for index registers immediates
+	mem.write(0x009000, 0xA2, 0x34, 0x12) // LDX #$1234
+	assert.Equal(t, "LDX #$34", Decode(mem, 0x009000, Context{M: false, X: true}).String())
+	assert.Equal(t, "LDX #$1234", Decode(mem, 0x009000, Context{M: true, X: false}).String())
+
+	// REP and SEP always take an 8-bit immediate
+	mem.write(0x00A000, 0xC2, 0x30)
+	assert.Equal(t, "REP #$30", Decode(mem, 0x00A000, Context{}).String())
+}
+
+func TestDecodeGuess(t *testing.T) {
+	mem := testMemory{}
+
+	// With an 8-bit immediate the next instruction would be a BRK
+	mem.write(0x008000, 0xA9, 0x00, 0x00, 0xEA)
+	assert.Equal(t, "LDA #$0000", Decode(mem, 0x008000, Context{Guess: true}).String())
+
+	mem.write(0x009000, 0xA9, 0x10, 0xEA)
+	assert.Equal(t, "LDA #$10", Decode(mem, 0x009000, Context{Guess: true}).String())
+}
+
+func TestDecodeTargets(t *testing.T) {
+	mem := testMemory{}
+	mem.write(0x7E8000, 0xD0, 0xFE)             // BNE $8000
+	mem.write(0x7E8002, 0x80, 0x10)             // BRA $8014
+	mem.write(0x7E8004, 0x82, 0x00, 0x80)       // BRL $0007
+	mem.write(0x7E8007, 0x22, 0x56, 0x34, 0x12) // JSL $123456
+	mem.write(0x7E800B, 0x20, 0x00, 0x90)       // JSR $9000
+	mem.write(0x7E800E, 0xAD, 0x00, 0x90)       // LDA $9000
+
+	for addr, expected := range map[uint32]struct {
+		text   string
+		target uint32
+	}{
+		0x7E8000: {"BNE $8000", 0x7E8000},
+		0x7E8002: {"BRA $8014", 0x7E8014},
+		0x7E8004: {"BRL $0007", 0x7E0007},
+		0x7E8007: {"JSL $123456", 0x123456},
+		0x7E800B: {"JSR $9000", 0x7E9000},
+	} {
+		i := Decode(mem, addr, Context{})
+		assert.Equal(t, expected.text, i.String())
+		target, ok := i.Target()
+		assert.True(t, ok)
+		assert.Equal(t, expected.target, target)
+	}
+
+	_, ok := Decode(mem, 0x7E800E, Context{}).Target()
+	assert.False(t, ok)
+}
+
+func TestDecodeEffectiveAddress(t *testing.T) {
+	mem := testMemory{}
+	r := &Registers{D: 0x0100, DBR: 0x7E, S: 0x01F0, X: 0x0002, Y: 0x0010}
+	mem.write(0x000112, 0x00, 0x20)       // pointer at D+$12
+	mem.write(0x000114, 0x00, 0x30, 0x7F) // long pointer at D+$14
+	mem.write(0x0001F3, 0x00, 0x40)       // pointer at S+$03
+	mem.write(0x008000, 0xC0, 0xDE)       // pointer at $7FFE+X
+
+	for expected, bytes := range map[string][]uint8{
+		"LDA $12 ; $000112":       {0xA5, 0x12},
+		"LDA $12,X ; $000114":     {0xB5, 0x12},
+		"LDX $12,Y ; $000122":     {0xB6, 0x12},
+		"LDA ($12) ; $7E2000":     {0xB2, 0x12},
+		"LDA ($10,X) ; $7E2000":   {0xA1, 0x10},
+		"LDA ($12),Y ; $7E2010":   {0xB1, 0x12},
+		"LDA [$14] ; $7F3000":     {0xA7, 0x14},
+		"LDA [$14],Y ; $7F3010":   {0xB7, 0x14},
+		"LDA $FFFF,X ; $7F0001":   {0xBD, 0xFF, 0xFF},
+		"LDA $123456,X ; $123458": {0xBF, 0x56, 0x34, 0x12},
+		"LDA $03,S ; $0001F3":     {0xA3, 0x03},
+		"LDA ($03,S),Y ; $7E4010": {0xB3, 0x03},
+		"JMP ($0112) ; $002000":   {0x6C, 0x12, 0x01},
+		"JMP [$0114] ; $7F3000":   {0xDC, 0x14, 0x01},
+		"MVN $12,$34":             {0x54, 0x34, 0x12},
+		"TAX":                     {0xAA},
+		"ASL A":                   {0x0A},
+		"PEA $1234":               {0xF4, 0x34, 0x12},
+		"BRK #$00":                {0x00, 0x00},
+		"STA $2100 ; $7E2100":     {0x8D, 0x00, 0x21},
+		"JMP ($7FFE,X) ; $00DEC0": {0x7C, 0xFE, 0x7F},
+	} {
+		mem.write(0x00A000, bytes...)
+		assert.Equal(t, expected, Decode(mem, 0x00A000, Context{Registers: r}).String(), "%X", bytes)
+	}
+}
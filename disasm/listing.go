@@ -0,0 +1,126 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteListing writes the assembly listing of the analyzed code to w
+// Every label is followed by the addresses referencing it, the targets of jumps and branches are replaced by their label
+func (p *Program) WriteListing(w io.Writer) error {
+	var addrs []uint32
+	for addr := range p.Instructions {
+		addrs = append(addrs, addr)
+	}
+	for addr := range p.Tables {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+	bw := bufio.NewWriter(w)
+	var end uint32
+	for n, addr := range addrs {
+		// separate the blocks of code which are not contiguous
+		if n > 0 && addr != end {
+			fmt.Fprintln(bw)
+		}
+
+		if i, ok := p.Instructions[addr]; ok {
+			p.writeLabel(bw, addr)
+			p.writeInstruction(bw, i)
+			end = addr + i.Size()
+		} else {
+			t := p.Tables[addr]
+			p.writeLabel(bw, addr)
+			p.writeTable(bw, t)
+			end = addr + t.Size()
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeLabel writes the label of an address and its cross-references, if it has one
+func (p *Program) writeLabel(w io.Writer, addr uint32) {
+	name, ok := p.Labels[addr]
+	if !ok {
+		return
+	}
+
+	line := name + ":"
+	if xrefs := p.XRefs[addr]; len(xrefs) > 0 {
+		refs := make([]string, len(xrefs))
+		for n, ref := range xrefs {
+			refs[n] = formatAddress(ref)
+		}
+		sort.Strings(refs)
+		line = fmt.Sprintf("%-30s ; xref: %s", line, strings.Join(refs, ", "))
+	}
+	fmt.Fprintln(w, line)
+}
+
+// writeInstruction writes an instruction with its address and its machine code
+func (p *Program) writeInstruction(w io.Writer, i Instruction) {
+	code := []uint8{i.Opcode}
+	line := fmt.Sprintf("%s  %-12s %s", formatAddress(i.Address), formatBytes(append(code, i.Operand...)), p.text(i))
+	if p.Guessed[i.Address] {
+		line = fmt.Sprintf("%-44s ; register width guessed", line)
+	}
+	fmt.Fprintln(w, line)
+}
+
+// writeTable writes the pointers of a table, one per line
+func (p *Program) writeTable(w io.Writer, t Table) {
+	size, directive := uint32(2), ".dw"
+	if t.Long {
+		size, directive = 3, ".dl"
+	}
+
+	for n, ptr := range t.Pointers(p.mem) {
+		addr := next(t.Address, uint32(n)*size)
+		code := make([]uint8, size)
+		for b := range code {
+			code[b] = p.mem.Peek(next(addr, uint32(b)))
+		}
+
+		operand, ok := p.Labels[ptr]
+		if !ok {
+			operand = fmt.Sprintf("$%06X", ptr)
+		}
+		fmt.Fprintf(w, "%s  %-12s %s %s\n", formatAddress(addr), formatBytes(code), directive, operand)
+	}
+}
+
+// text returns the instruction in assembly syntax, known addresses are replaced by their label
+func (p *Program) text(i Instruction) string {
+	if target, ok := i.Target(); ok {
+		if name, ok := p.Labels[target]; ok {
+			return i.Mnemonic + " " + name
+		}
+	}
+
+	if i.Mode == AbsoluteXIndirect {
+		if name, ok := p.Labels[i.Address&0xFF0000|i.value()]; ok {
+			return fmt.Sprintf("%s (%s,X)", i.Mnemonic, name)
+		}
+	}
+
+	return i.String()
+}
+
+// formatAddress formats an address the same way as the CPU logs
+func formatAddress(addr uint32) string {
+	return fmt.Sprintf("$%02X:%04X", addr>>16, addr&0xFFFF)
+}
+
+// formatBytes formats machine code as hexadecimal bytes
+func formatBytes(code []uint8) string {
+	res := make([]string, len(code))
+	for n, b := range code {
+		res[n] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(res, " ")
+}
@@ -0,0 +1,268 @@
+package disasm
+
+// Opcode describes an instruction of the 65C816
+type Opcode struct {
+	Mnemonic string
+	Mode     Mode
+}
+
+// Opcodes holds the mnemonic and the addressing mode of every opcode
+// See: https://wiki.superfamicom.org/65816-reference
+var Opcodes = [0x100]Opcode{
+	{"BRK", Immediate8},             // 00
+	{"ORA", DirectXIndirect},        // 01
+	{"COP", Immediate8},             // 02
+	{"ORA", StackRelative},          // 03
+	{"TSB", Direct},                 // 04
+	{"ORA", Direct},                 // 05
+	{"ASL", Direct},                 // 06
+	{"ORA", DirectIndirectLong},     // 07
+	{"PHP", Implied},                // 08
+	{"ORA", ImmediateM},             // 09
+	{"ASL", Accumulator},            // 0A
+	{"PHD", Implied},                // 0B
+	{"TSB", Absolute},               // 0C
+	{"ORA", Absolute},               // 0D
+	{"ASL", Absolute},               // 0E
+	{"ORA", AbsoluteLong},           // 0F
+	{"BPL", Relative},               // 10
+	{"ORA", DirectIndirectY},        // 11
+	{"ORA", DirectIndirect},         // 12
+	{"ORA", StackRelativeIndirectY}, // 13
+	{"TRB", Direct},                 // 14
+	{"ORA", DirectX},                // 15
+	{"ASL", DirectX},                // 16
+	{"ORA", DirectIndirectLongY},    // 17
+	{"CLC", Implied},                // 18
+	{"ORA", AbsoluteY},              // 19
+	{"INC", Accumulator},            // 1A
+	{"TCS", Implied},                // 1B
+	{"TRB", Absolute},               // 1C
+	{"ORA", AbsoluteX},              // 1D
+	{"ASL", AbsoluteX},              // 1E
+	{"ORA", AbsoluteLongX},          // 1F
+	{"JSR", Absolute},               // 20
+	{"AND", DirectXIndirect},        // 21
+	{"JSL", AbsoluteLong},           // 22
+	{"AND", StackRelative},          // 23
+	{"BIT", Direct},                 // 24
+	{"AND", Direct},                 // 25
+	{"ROL", Direct},                 // 26
+	{"AND", DirectIndirectLong},     // 27
+	{"PLP", Implied},                // 28
+	{"AND", ImmediateM},             // 29
+	{"ROL", Accumulator},            // 2A
+	{"PLD", Implied},                // 2B
+	{"BIT", Absolute},               // 2C
+	{"AND", Absolute},               // 2D
+	{"ROL", Absolute},               // 2E
+	{"AND", AbsoluteLong},           // 2F
+	{"BMI", Relative},               // 30
+	{"AND", DirectIndirectY},        // 31
+	{"AND", DirectIndirect},         // 32
+	{"AND", StackRelativeIndirectY}, // 33
+	{"BIT", DirectX},                // 34
+	{"AND", DirectX},                // 35
+	{"ROL", DirectX},                // 36
+	{"AND", DirectIndirectLongY},    // 37
+	{"SEC", Implied},                // 38
+	{"AND", AbsoluteY},              // 39
+	{"DEC", Accumulator},            // 3A
+	{"TSC", Implied},                // 3B
+	{"BIT", AbsoluteX},              // 3C
+	{"AND", AbsoluteX},              // 3D
+	{"ROL", AbsoluteX},              // 3E
+	{"AND", AbsoluteLongX},          // 3F
+	{"RTI", Implied},                // 40
+	{"EOR", DirectXIndirect},        // 41
+	{"WDM", Immediate8},             // 42
+	{"EOR", StackRelative},          // 43
+	{"MVP", BlockMove},              // 44
+	{"EOR", Direct},                 // 45
+	{"LSR", Direct},                 // 46
+	{"EOR", DirectIndirectLong},     // 47
+	{"PHA", Implied},                // 48
+	{"EOR", ImmediateM},             // 49
+	{"LSR", Accumulator},            // 4A
+	{"PHK", Implied},                // 4B
+	{"JMP", Absolute},               // 4C
+	{"EOR", Absolute},               // 4D
+	{"LSR", Absolute},               // 4E
+	{"EOR", AbsoluteLong},           // 4F
+	{"BVC", Relative},               // 50
+	{"EOR", DirectIndirectY},        // 51
+	{"EOR", DirectIndirect},         // 52
+	{"EOR", StackRelativeIndirectY}, // 53
+	{"MVN", BlockMove},              // 54
+	{"EOR", DirectX},                // 55
+	{"LSR", DirectX},                // 56
+	{"EOR", DirectIndirectLongY},    // 57
+	{"CLI", Implied},                // 58
+	{"EOR", AbsoluteY},              // 59
+	{"PHY", Implied},                // 5A
+	{"TCD", Implied},                // 5B
+	{"JMP", AbsoluteLong},           // 5C
+	{"EOR", AbsoluteX},              // 5D
+	{"LSR", AbsoluteX},              // 5E
+	{"EOR", AbsoluteLongX},          // 5F
+	{"RTS", Implied},                // 60
+	{"ADC", DirectXIndirect},        // 61
+	{"PER", RelativeLong},           // 62
+	{"ADC", StackRelative},          // 63
+	{"STZ", Direct},                 // 64
+	{"ADC", Direct},                 // 65
+	{"ROR", Direct},                 // 66
+	{"ADC", DirectIndirectLong},     // 67
+	{"PLA", Implied},                // 68
+	{"ADC", ImmediateM},             // 69
+	{"ROR", Accumulator},            // 6A
+	{"RTL", Implied},                // 6B
+	{"JMP", AbsoluteIndirect},       // 6C
+	{"ADC", Absolute},               // 6D
+	{"ROR", Absolute},               // 6E
+	{"ADC", AbsoluteLong},           // 6F
+	{"BVS", Relative},               // 70
+	{"ADC", DirectIndirectY},        // 71
+	{"ADC", DirectIndirect},         // 72
+	{"ADC", StackRelativeIndirectY}, // 73
+	{"STZ", DirectX},                // 74
+	{"ADC", DirectX},                // 75
+	{"ROR", DirectX},                // 76
+	{"ADC", DirectIndirectLongY},    // 77
+	{"SEI", Implied},                // 78
+	{"ADC", AbsoluteY},              // 79
+	{"PLY", Implied},                // 7A
+	{"TDC", Implied},                // 7B
+	{"JMP", AbsoluteXIndirect},      // 7C
+	{"ADC", AbsoluteX},              // 7D
+	{"ROR", AbsoluteX},              // 7E
+	{"ADC", AbsoluteLongX},          // 7F
+	{"BRA", Relative},               // 80
+	{"STA", DirectXIndirect},        // 81
+	{"BRL", RelativeLong},           // 82
+	{"STA", StackRelative},          // 83
+	{"STY", Direct},                 // 84
+	{"STA", Direct},                 // 85
+	{"STX", Direct},                 // 86
+	{"STA", DirectIndirectLong},     // 87
+	{"DEY", Implied},                // 88
+	{"BIT", ImmediateM},             // 89
+	{"TXA", Implied},                // 8A
+	{"PHB", Implied},                // 8B
+	{"STY", Absolute},               // 8C
+	{"STA", Absolute},               // 8D
+	{"STX", Absolute},               // 8E
+	{"STA", AbsoluteLong},           // 8F
+	{"BCC", Relative},               // 90
+	{"STA", DirectIndirectY},        // 91
+	{"STA", DirectIndirect},         // 92
+	{"STA", StackRelativeIndirectY}, // 93
+	{"STY", DirectX},                // 94
+	{"STA", DirectX},                // 95
+	{"STX", DirectY},                // 96
+	{"STA", DirectIndirectLongY},    // 97
+	{"TYA", Implied},                // 98
+	{"STA", AbsoluteY},              // 99
+	{"TXS", Implied},                // 9A
+	{"TXY", Implied},                // 9B
+	{"STZ", Absolute},               // 9C
+	{"STA", AbsoluteX},              // 9D
+	{"STZ", AbsoluteX},              // 9E
+	{"STA", AbsoluteLongX},          // 9F
+	{"LDY", ImmediateX},             // A0
+	{"LDA", DirectXIndirect},        // A1
+	{"LDX", ImmediateX},             // A2
+	{"LDA", StackRelative},          // A3
+	{"LDY", Direct},                 // A4
+	{"LDA", Direct},                 // A5
+	{"LDX", Direct},                 // A6
+	{"LDA", DirectIndirectLong},     // A7
+	{"TAY", Implied},                // A8
+	{"LDA", ImmediateM},             // A9
+	{"TAX", Implied},                // AA
+	{"PLB", Implied},                // AB
+	{"LDY", Absolute},               // AC
+	{"LDA", Absolute},               // AD
+	{"LDX", Absolute},               // AE
+	{"LDA", AbsoluteLong},           // AF
+	{"BCS", Relative},               // B0
+	{"LDA", DirectIndirectY},        // B1
+	{"LDA", DirectIndirect},         // B2
+	{"LDA", StackRelativeIndirectY}, // B3
+	{"LDY", DirectX},                // B4
+	{"LDA", DirectX},                // B5
+	{"LDX", DirectY},                // B6
+	{"LDA", DirectIndirectLongY},    // B7
+	{"CLV", Implied},                // B8
+	{"LDA", AbsoluteY},              // B9
+	{"TSX", Implied},                // BA
+	{"TYX", Implied},                // BB
+	{"LDY", AbsoluteX},              // BC
+	{"LDA", AbsoluteX},              // BD
+	{"LDX", AbsoluteY},              // BE
+	{"LDA", AbsoluteLongX},          // BF
+	{"CPY", ImmediateX},             // C0
+	{"CMP", DirectXIndirect},        // C1
+	{"REP", Immediate8},             // C2
+	{"CMP", StackRelative},          // C3
+	{"CPY", Direct},                 // C4
+	{"CMP", Direct},                 // C5
+	{"DEC", Direct},                 // C6
+	{"CMP", DirectIndirectLong},     // C7
+	{"INY", Implied},                // C8
+	{"CMP", ImmediateM},             // C9
+	{"DEX", Implied},                // CA
+	{"WAI", Implied},                // CB
+	{"CPY", Absolute},               // CC
+	{"CMP", Absolute},               // CD
+	{"DEC", Absolute},               // CE
+	{"CMP", AbsoluteLong},           // CF
+	{"BNE", Relative},               // D0
+	{"CMP", DirectIndirectY},        // D1
+	{"CMP", DirectIndirect},         // D2
+	{"CMP", StackRelativeIndirectY}, // D3
+	{"PEI", DirectIndirect},         // D4
+	{"CMP", DirectX},                // D5
+	{"DEC", DirectX},                // D6
+	{"CMP", DirectIndirectLongY},    // D7
+	{"CLD", Implied},                // D8
+	{"CMP", AbsoluteY},              // D9
+	{"PHX", Implied},                // DA
+	{"STP", Implied},                // DB
+	{"JMP", AbsoluteIndirectLong},   // DC
+	{"CMP", AbsoluteX},              // DD
+	{"DEC", AbsoluteX},              // DE
+	{"CMP", AbsoluteLongX},          // DF
+	{"CPX", ImmediateX},             // E0
+	{"SBC", DirectXIndirect},        // E1
+	{"SEP", Immediate8},             // E2
+	{"SBC", StackRelative},          // E3
+	{"CPX", Direct},                 // E4
+	{"SBC", Direct},                 // E5
+	{"INC", Direct},                 // E6
+	{"SBC", DirectIndirectLong},     // E7
+	{"INX", Implied},                // E8
+	{"SBC", ImmediateM},             // E9
+	{"NOP", Implied},                // EA
+	{"XBA", Implied},                // EB
+	{"CPX", Absolute},               // EC
+	{"SBC", Absolute},               // ED
+	{"INC", Absolute},               // EE
+	{"SBC", AbsoluteLong},           // EF
+	{"BEQ", Relative},               // F0
+	{"SBC", DirectIndirectY},        // F1
+	{"SBC", DirectIndirect},         // F2
+	{"SBC", StackRelativeIndirectY}, // F3
+	{"PEA", Immediate16},            // F4
+	{"SBC", DirectX},                // F5
+	{"INC", DirectX},                // F6
+	{"SBC", DirectIndirectLongY},    // F7
+	{"SED", Implied},                // F8
+	{"SBC", AbsoluteY},              // F9
+	{"PLX", Implied},                // FA
+	{"XCE", Implied},                // FB
+	{"JSR", AbsoluteXIndirect},      // FC
+	{"SBC", AbsoluteX},              // FD
+	{"INC", AbsoluteX},              // FE
+	{"SBC", AbsoluteLongX},          // FF
+}
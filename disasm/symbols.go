@@ -0,0 +1,65 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseAddress parses a 24-bit address written as BB:AAAA, $BBAAAA, 0xBBAAAA or BBAAAA (hexadecimal)
+func ParseAddress(s string) (uint32, error) {
+	hex := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(s, "$"), "0x"), "0X")
+	if parts := strings.Split(hex, ":"); len(parts) == 2 {
+		if len(parts[0]) > 2 || len(parts[1]) > 4 {
+			return 0, fmt.Errorf("invalid address %q", s)
+		}
+		hex = parts[0] + strings.Repeat("0", 4-len(parts[1])) + parts[1]
+	}
+
+	addr, err := strconv.ParseUint(hex, 16, 24)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+	return uint32(addr), nil
+}
+
+// ReadSymbols reads a symbol file, which holds one "<address> <name>" pair per line (the WLA-DX format)
+// Empty lines and comments starting with ; or # are ignored, if the file is divided in sections
+// only the [labels] section is read
+func ReadSymbols(r io.Reader) (map[uint32]string, error) {
+	res := make(map[uint32]string)
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if idx := strings.IndexAny(text, ";#"); idx >= 0 {
+			text = text[:idx]
+		}
+		text = strings.TrimSpace(text)
+
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "["):
+			section = text
+			continue
+		case section != "" && section != "[labels]":
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected an address and a name, got %q", line, text)
+		}
+		addr, err := ParseAddress(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		res[addr] = fields[1]
+	}
+
+	return res, scanner.Err()
+}
@@ -15,7 +15,8 @@ type Register struct {
 }
 
 type RegisterFactory struct {
-	hook registerHook
+	hook    registerHook
+	openBus func() uint8
 }
 
 func NewRegisterFactory() *RegisterFactory {
@@ -32,7 +33,7 @@ func (rf *RegisterFactory) NewRegister(read func() uint8, write func(uint8), nam
 	regname := UNUSED_REGISTER
 
 	if read == nil {
-		read = unusedRead
+		read = rf.unusedRead
 	}
 	if write == nil {
 		write = unusedWrite
@@ -64,8 +65,17 @@ func (rf *RegisterFactory) NewRegister(read func() uint8, write func(uint8), nam
 	return r
 }
 
-func unusedRead() uint8 {
-	return 0
+// SetOpenBus sets the function returning the last value on the data bus,
+// which is what the unused and write-only registers return when they are read
+func (rf *RegisterFactory) SetOpenBus(openBus func() uint8) {
+	rf.openBus = openBus
+}
+
+func (rf *RegisterFactory) unusedRead() uint8 {
+	if rf.openBus == nil {
+		return 0
+	}
+	return rf.openBus()
 }
 
 func unusedWrite(_ uint8) {
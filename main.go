@@ -20,10 +20,24 @@ import (
 // VERSION set at compile time
 var VERSION string
 
+// commands are the tools run instead of the emulator when their name is given before the arguments
+var commands = map[string]func(args []string) error{
+	"disasm":    runDisasm,
+	"tracediff": runTraceDiff,
+}
+
 func main() {
 	config.Init()
 	log.Init()
 
+	if command, ok := commands[flag.Arg(0)]; ok {
+		if err := command(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	log.Info("starting gose", zap.String("version", VERSION))
 
 	if len(flag.Args()) == 0 {
@@ -31,8 +45,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	// TODO: fix dimension
-	renderer, err := render.NewRenderer(int(core.WIDTH), int(core.HEIGHT))
+	renderer, err := render.NewRenderer(int(core.ScreenWidth()), int(core.ScreenHeight()))
 	if err != nil {
 		log.Fatal("failed to init renderer", zap.Error(err))
 	}
@@ -46,6 +59,8 @@ func main() {
 	}
 
 	emu.Start()
+	// the window can be closed without any signal, the trace must be written before exiting
+	defer emu.Stop()
 
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -14,6 +14,12 @@ import (
 	"github.com/hajimehoshi/ebiten"
 )
 
+//windowWidth and windowHeight are the logical size of the window: twice the SNES low-res resolution
+const (
+	windowWidth  = 512
+	windowHeight = 478
+)
+
 //EbitenRenderer is a Renderer implementation using ebiten
 type EbitenRenderer struct {
 	width           int
@@ -30,13 +36,18 @@ func newEbitenRenderer(width, height int) (Renderer, error) {
 	//We use this offscreen buffer because we don't want our SNES main loop to be tied to the ebiten one
 	//NewImage always returns a nil error
 	offscreenBuffer, _ := ebiten.NewImage(width, height, ebiten.FilterDefault)
+
+	//Frames are stretched to the window size so hi-res and interlaced frames keep the SNES aspect ratio
+	drawOptions := &ebiten.DrawImageOptions{}
+	drawOptions.GeoM.Scale(float64(windowWidth)/float64(width), float64(windowHeight)/float64(height))
+
 	er := &EbitenRenderer{
 		width:           width,
 		height:          height,
-		scale:           2.0,
+		scale:           1.0,
 		title:           "Gose",
 		offscreenBuffer: offscreenBuffer,
-		drawOptions:     &ebiten.DrawImageOptions{},
+		drawOptions:     drawOptions,
 	}
 
 	ebiten.SetWindowIcon(getWindowLogos())
@@ -78,7 +89,7 @@ func (er *EbitenRenderer) update(screen *ebiten.Image) error {
 //Run starts the ebiten main loop
 //should be called on the main thread
 func (er *EbitenRenderer) Run() {
-	err := ebiten.Run(er.update, er.width, er.height, er.scale, er.title)
+	err := ebiten.Run(er.update, windowWidth, windowHeight, er.scale, er.title)
 	if err != nil {
 		log.Fatal("ebiten crashed", zap.Error(err))
 	}
@@ -45,6 +45,18 @@ func (c BGR555) ApplyBrightness(bness uint8) BGR555 {
 	}
 }
 
+// Blend returns the average of the two colors
+func (c BGR555) Blend(o BGR555) BGR555 {
+	r := (c.Color&0x1f + o.Color&0x1f) >> 1
+	g := ((c.Color>>5)&0x1f + (o.Color>>5)&0x1f) >> 1
+	b := ((c.Color>>10)&0x1f + (o.Color>>10)&0x1f) >> 1
+
+	return BGR555{
+		Color:       b<<10 | g<<5 | r,
+		Transparent: c.Transparent && o.Transparent,
+	}
+}
+
 // RGBA implements the color.Color interface
 func (c BGR555) RGBA() (r, g, b, a uint32) {
 	a = 0xFFFF
@@ -15,24 +15,50 @@ type Screen struct {
 
 func NewScreen(width, height uint16) *Screen {
 	return &Screen{
-		Pixels: make([]Pixel, width*height),
+		Pixels: make([]Pixel, int(width)*int(height)),
 		Width:  width,
 		Height: height,
 		model:  color.ModelFunc(bgr555ModelFunc),
 	}
 }
 
+// SetPixelLine sets the visible pixels of the given line
+// Lines that are not as wide as the screen are scaled: 256 pixels lines are doubled on a 512 pixels wide screen
+// and 512 pixels lines are blended down on a 256 pixels wide screen
 func (s *Screen) SetPixelLine(line uint16, pixels []Pixel) {
-	if line < s.Height {
-		start := int(line * s.Width)
-		for i, pix := range pixels[:s.Width] {
-			if pix.Visible {
-				s.Pixels[start+i] = pix
-			}
-		}
-	} else {
+	if line >= s.Height {
 		panic(fmt.Sprintf("Screen not big enough ! can't set pixels at line %d in screen having only %d lines", line, s.Height))
 	}
+
+	start := int(line) * int(s.Width)
+	for i := 0; i < int(s.Width); i++ {
+		var pix Pixel
+		switch {
+		case len(pixels) == int(s.Width):
+			pix = pixels[i]
+		case len(pixels) < int(s.Width):
+			pix = pixels[i*len(pixels)/int(s.Width)]
+		default:
+			pix = blendPixels(pixels[2*i], pixels[2*i+1])
+		}
+
+		if pix.Visible {
+			s.Pixels[start+i] = pix
+		}
+	}
+}
+
+// blendPixels blends 2 adjacent hi-res pixels in a single one
+func blendPixels(left, right Pixel) Pixel {
+	if !left.Visible {
+		return right
+	}
+	if !right.Visible {
+		return left
+	}
+
+	left.Color = left.Color.Blend(right.Color)
+	return left
 }
 
 func (s *Screen) Bounds() image.Rectangle {
@@ -15,6 +15,13 @@ const (
 	maxSRAMSize = 9
 )
 
+const (
+	// NTSC region (60Hz)
+	NTSC = iota
+	// PAL region (50Hz)
+	PAL
+)
+
 // ROM struct
 type ROM struct {
 	Data     []byte // Raw bytes of the rom
@@ -23,6 +30,7 @@ type ROM struct {
 	isFast   bool   // Whether or not the ROM is of type Fast
 	SRAMSize uint   // SRAM size
 	Type     uint   // Type of the Rom (LoROM, HiROM, ExLoROM, ExHiROM)
+	Region   uint   // Region of the Rom (NTSC, PAL)
 }
 
 // ParseROM parses a ROM file representation in bytes and return a representation
@@ -66,10 +74,21 @@ func ParseROM(data []byte) (*ROM, error) {
 		rom.SRAMSize = 0x400 << sramSize
 	}
 	rom.Type = romType
+	rom.Region = region(rom.Data[headerAddr+25])
 
 	return rom, nil
 }
 
+// region returns the region associated to the country code of the header
+// Europe, Asia (except Japan and Korea) and Australia use PAL consoles
+func region(countryCode uint8) uint {
+	if (countryCode >= 0x02 && countryCode <= 0x0C) || countryCode == 0x11 {
+		return PAL
+	}
+
+	return NTSC
+}
+
 // isLo checks if the ROM is of type LoROM
 func (rom *ROM) isLo() bool {
 	for _, c := range rom.Data[0x7fc0:0x7fd4] {
@@ -81,3 +100,57 @@ func (rom *ROM) isLo() bool {
 
 	return true
 }
+
+// Offset returns the offset in the ROM data of the byte mapped at the given address of the CPU
+// The second value is false if the address is not mapped to the ROM
+func (rom *ROM) Offset(addr uint32) (int, bool) {
+	K, offset := addr>>16, int(addr&0xFFFF)
+
+	// banks $7E and $7F are mapped to the work RAM
+	if K == 0x7E || K == 0x7F || len(rom.Data) == 0 {
+		return 0, false
+	}
+
+	// upper banks are mirrors of the lower ones
+	bank := int(K & 0x7F)
+
+	var pos int
+	switch rom.Type {
+	case LoROM:
+		// the lower halves of banks $40-$6F mirror the upper ones, the other lower halves are not mapped to the ROM
+		if offset < 0x8000 && (bank < 0x40 || bank >= 0x70) {
+			return 0, false
+		}
+		pos = bank*0x8000 + offset&0x7FFF
+	case HiROM:
+		if bank < 0x40 && offset < 0x8000 {
+			return 0, false
+		}
+		pos = (bank&0x3F)*0x10000 + offset
+	default:
+		return 0, false
+	}
+
+	return mirror(pos, len(rom.Data)), true
+}
+
+// mirror returns the position in a ROM of the given size of the byte at pos in the 4MB ROM address space
+// A ROM is made of chips whose sizes are powers of two: the address space is filled with mirrors of the first chip,
+// then the part of the address space after it with mirrors of the next one, and so on (a 12Mbit ROM is mapped as
+// 8Mbit + 4Mbit + 4Mbit, a 20Mbit one as 16Mbit + 4Mbit * 4 and a 24Mbit one as 16Mbit + 8Mbit + 8Mbit)
+func mirror(pos, size int) int {
+	base := 0
+	mask := 1 << 23
+	for pos >= size {
+		for pos&mask == 0 {
+			mask >>= 1
+		}
+		pos -= mask
+		if size > mask {
+			size -= mask
+			base += mask
+		}
+		mask >>= 1
+	}
+	return base + pos
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/snes-emu/gose/tracediff"
+)
+
+// errTracesDiffer is returned by the tracediff command when the traces diverge
+var errTracesDiffer = fmt.Errorf("the traces differ")
+
+// runTraceDiff runs the tracediff command: it compares two CPU traces and reports the first instruction where they diverge
+func runTraceDiff(args []string) error {
+	flags := flag.NewFlagSet("tracediff", flag.ExitOnError)
+	fields := flags.String("fields", strings.Join(tracediff.DefaultFields, ","), "comma separated list of the compared fields (PC, OP, A, X, Y, S, D, DB, P, V, H, CYC, F)")
+	alignPC := flags.Bool("align-pc", false, "skip the start of the second trace until it reaches the address of the first instruction of the first trace")
+	context := flags.Int("context", 5, "number of instructions displayed before the first difference")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gose tracediff [flags] <trace a> <trace b>")
+		fmt.Fprintln(flags.Output(), "Traces can be in the gose, bsnes or mesen layout (see the -trace-format flag) or gose debug logs, .gz traces are decompressed")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("please provide the two traces to compare")
+	}
+
+	a, closeA, err := tracediff.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closeA.Close()
+
+	b, closeB, err := tracediff.Open(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer closeB.Close()
+
+	n, divergence, err := tracediff.Compare(a, b, tracediff.Options{
+		Fields:  strings.Split(strings.ToUpper(*fields), ","),
+		AlignPC: *alignPC,
+		Context: *context,
+	})
+	if err != nil {
+		return err
+	}
+
+	if divergence != nil {
+		fmt.Print(divergence)
+		return errTracesDiffer
+	}
+
+	fmt.Printf("the traces are identical (%d instructions)\n", n)
+	return nil
+}
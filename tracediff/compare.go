@@ -0,0 +1,153 @@
+package tracediff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultFields are the fields compared by default, the counters (V, H, CYC and F) are left out
+// as their meaning differs between emulators
+var DefaultFields = []string{"PC", "OP", "A", "X", "Y", "S", "D", "DB", "P"}
+
+// Options configure the comparison of two traces
+type Options struct {
+	Fields  []string // compared fields, a field missing from one of the traces is not compared
+	AlignPC bool     // skip the start of trace b until it reaches the first address of trace a, otherwise both are compared from their first instruction
+	Context int      // number of instructions reported before the divergence
+}
+
+// Divergence is the first difference found between two traces
+type Divergence struct {
+	Index  int    // index of the instruction in the compared instructions
+	Field  string // field which differs, empty if one of the traces is over
+	A, B   *Entry // instructions which differ, nil for a trace which is over
+	Before [][2]Entry
+}
+
+// String describes the divergence with the instructions before it
+func (d *Divergence) String() string {
+	var sb strings.Builder
+	for _, e := range d.Before {
+		fmt.Fprintf(&sb, "  a:%-8d %s\n  b:%-8d %s\n", e[0].Line, e[0].Text, e[1].Line, e[1].Text)
+	}
+
+	switch {
+	case d.A == nil:
+		fmt.Fprintf(&sb, "trace a is over after %d instructions, trace b goes on with:\n  b:%-8d %s\n", d.Index, d.B.Line, d.B.Text)
+	case d.B == nil:
+		fmt.Fprintf(&sb, "trace b is over after %d instructions, trace a goes on with:\n  a:%-8d %s\n", d.Index, d.A.Line, d.A.Text)
+	default:
+		a, _ := d.A.value(d.Field)
+		b, _ := d.B.value(d.Field)
+		fmt.Fprintf(&sb, "> a:%-8d %s\n> b:%-8d %s\n", d.A.Line, d.A.Text, d.B.Line, d.B.Text)
+		fmt.Fprintf(&sb, "instruction %d differs on %s: %s != %s\n", d.Index, d.Field, a, b)
+	}
+	return sb.String()
+}
+
+// value returns the value of a field formatted for display, the second value is false if the field is not in the trace
+func (e *Entry) value(field string) (string, bool) {
+	switch field {
+	case "PC":
+		return fmt.Sprintf("$%02X:%04X", e.PC>>16, e.PC&0xFFFF), true
+	case "OP":
+		return e.Op, true
+	default:
+		v, ok := e.Fields[field]
+		if field == "P" {
+			return formatFlags(v), ok
+		}
+		return fmt.Sprintf("$%X", v), ok
+	}
+}
+
+// formatFlags formats the processor status flags as letters, in upper case when set
+func formatFlags(p uint32) string {
+	res := []byte("nvmxdizc")
+	for n := range res {
+		if p&(0x80>>uint(n)) != 0 {
+			res[n] -= 'a' - 'A'
+		}
+	}
+	return string(res)
+}
+
+// diff returns the first compared field which differs between two instructions, empty if none does
+func diff(a, b *Entry, fields []string) string {
+	for _, field := range fields {
+		va, okA := a.value(field)
+		vb, okB := b.value(field)
+		if okA && okB && va != vb {
+			return field
+		}
+	}
+	return ""
+}
+
+// Compare reads both traces until they diverge, it returns the number of compared instructions
+// and the divergence, which is nil if the traces are identical
+func Compare(a, b *Reader, opts Options) (int, *Divergence, error) {
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+
+	ea, okA, err := a.Next()
+	if err != nil {
+		return 0, nil, err
+	}
+	eb, okB, err := b.Next()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if opts.AlignPC && okA && okB {
+		if eb, okB, err = align(b, ea, eb); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var before [][2]Entry
+	for n := 0; ; n++ {
+		switch {
+		case !okA && !okB:
+			return n, nil, nil
+		case !okA:
+			return n, &Divergence{Index: n, B: &eb, Before: before}, nil
+		case !okB:
+			return n, &Divergence{Index: n, A: &ea, Before: before}, nil
+		}
+
+		if field := diff(&ea, &eb, fields); field != "" {
+			return n, &Divergence{Index: n, Field: field, A: &ea, B: &eb, Before: before}, nil
+		}
+
+		if opts.Context > 0 {
+			if len(before) == opts.Context {
+				before = before[1:]
+			}
+			before = append(before, [2]Entry{ea, eb})
+		}
+
+		if ea, okA, err = a.Next(); err != nil {
+			return n, nil, err
+		}
+		if eb, okB, err = b.Next(); err != nil {
+			return n, nil, err
+		}
+	}
+}
+
+// align skips the start of trace b until it reaches the address of the first instruction of trace a
+func align(b *Reader, ea, eb Entry) (Entry, bool, error) {
+	for eb.PC != ea.PC {
+		var (
+			ok  bool
+			err error
+		)
+		if eb, ok, err = b.Next(); err != nil || !ok {
+			return eb, ok, err
+		}
+	}
+	return eb, true, nil
+}
@@ -0,0 +1,128 @@
+// Package tracediff compares CPU traces to find the first instruction where they diverge
+package tracediff
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Entry is an instruction parsed from a trace line
+type Entry struct {
+	Line   int    // line number in the trace file
+	Text   string // raw trace line
+	PC     uint32 // address of the instruction (program bank and program counter)
+	Op     string // mnemonic of the instruction, in upper case
+	Fields map[string]uint32
+}
+
+var (
+	// instructionPattern matches the address of the instruction (written as $BB:AAAA, BB:AAAA or BBAAAA), followed by
+	// the optional machine code and the disassembly of the instruction
+	instructionPattern = regexp.MustCompile(`(?:^|[\s$])([0-9A-Fa-f]{2}):?([0-9A-Fa-f]{4})\s+(?:\$?[0-9A-Fa-f]{2}\s+){0,4}([A-Za-z]{3})\b.*?\sA:[0-9A-Fa-f]{4}`)
+	// registerPattern matches the registers and the counters, B: is an alias of DB:
+	registerPattern = regexp.MustCompile(`\b(A|X|Y|S|D|DB|B|V|H|CYC|F):\s*([0-9A-Fa-f]+)\b`)
+	// flagsPattern matches the processor status flags written as letters, lower case when cleared (the E flag is optional)
+	flagsPattern = regexp.MustCompile(`(?:\bP:|\s)[eE]?([nN][vV][mM][xX][dD][iI][zZ][cC])\b`)
+)
+
+// decimalFields are the counters written in decimal in the traces
+var decimalFields = map[string]bool{"V": true, "H": true, "CYC": true, "F": true}
+
+// Parse parses a trace line in the gose, bsnes or mesen layout, or a CPU log line of gose
+// The second value is false if the line is not an instruction
+func Parse(line string) (Entry, bool) {
+	m := instructionPattern.FindStringSubmatchIndex(line)
+	if m == nil {
+		return Entry{}, false
+	}
+
+	bank, _ := strconv.ParseUint(line[m[2]:m[3]], 16, 8)
+	offset, _ := strconv.ParseUint(line[m[4]:m[5]], 16, 16)
+	e := Entry{
+		Text:   line,
+		PC:     uint32(bank)<<16 | uint32(offset),
+		Op:     strings.ToUpper(line[m[6]:m[7]]),
+		Fields: make(map[string]uint32),
+	}
+
+	// the registers are after the disassembly, which can hold anything
+	registers := line[m[7]:]
+	if idx := strings.Index(registers, " A:"); idx >= 0 {
+		registers = registers[idx:]
+	}
+
+	for _, r := range registerPattern.FindAllStringSubmatch(registers, -1) {
+		name := r[1]
+		if name == "B" {
+			name = "DB"
+		}
+
+		base := 16
+		if decimalFields[name] {
+			base = 10
+		}
+		if v, err := strconv.ParseUint(r[2], base, 64); err == nil {
+			e.Fields[name] = uint32(v)
+		}
+	}
+
+	if f := flagsPattern.FindStringSubmatch(registers); f != nil {
+		var p uint32
+		for n, c := range f[1] {
+			if c >= 'A' && c <= 'Z' {
+				p |= 0x80 >> uint(n)
+			}
+		}
+		e.Fields["P"] = p
+	}
+
+	return e, true
+}
+
+// Reader reads the instructions of a trace, the lines which are not instructions are skipped
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader creates a Reader reading a trace from r
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &Reader{scanner: scanner}
+}
+
+// Next returns the next instruction of the trace, the second value is false once the trace is over
+func (r *Reader) Next() (Entry, bool, error) {
+	for r.scanner.Scan() {
+		r.line++
+		if e, ok := Parse(r.scanner.Text()); ok {
+			e.Line = r.line
+			return e, true, nil
+		}
+	}
+	return Entry{}, false, r.scanner.Err()
+}
+
+// Open opens a trace file, traces compressed with gzip are decompressed if the file name ends with .gz
+func Open(filename string) (*Reader, io.Closer, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.HasSuffix(filename, ".gz") {
+		return NewReader(f), f, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return NewReader(gz), f, nil
+}
@@ -0,0 +1,98 @@
+package tracediff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	expected := map[string]uint32{"A": 0x1234, "X": 0x0001, "Y": 0x0002, "S": 0x01FF, "D": 0x0000, "DB": 0x7E, "P": 0x25}
+	for _, line := range []string{
+		"$00:8001 LDA $10 ; $000010        A:1234 X:0001 Y:0002 D:0000 DB:7E S:01FF P:envMxdIzC V:  0 H: 12 CYC:48",
+		"2020-01-01T00:00:00.000Z\tDEBUG\tcore/cpu_start.go:137\t$00:8001 LDA $10 ; $000010        A:1234 X:0001 Y:0002 D:0000 DB:7E S:01FF P:EnvMxdIzC",
+		"008001 lda $10 [000010]       A:1234 X:0001 Y:0002 S:01ff D:0000 DB:7e nvMxdIzC V:  0 H:  48 F: 0",
+		"008001 lda $10      [000010] A:1234 X:0001 Y:0002 S:01ff D:0000 B:7e nvMxdIzC V:0 H:48",
+		"008001  LDA $10 [$000010]              A:1234 X:0001 Y:0002 S:01FF D:0000 DB:7E P:nvMxdIzC H:48   V:0   CYC:48",
+		"00:8001 $A5 $10    LDA $10 [$000010] A:1234 X:0001 Y:0002 S:01FF D:0000 DB:7E P:nvMxdIzC",
+	} {
+		e, ok := Parse(line)
+		assert.True(t, ok, line)
+		assert.EqualValues(t, 0x008001, e.PC, line)
+		assert.Equal(t, "LDA", e.Op, line)
+		for field, value := range expected {
+			assert.Equal(t, value, e.Fields[field], "%s: %s", field, line)
+		}
+	}
+
+	e, _ := Parse("$00:8000 ASL A                    A:0000 X:0000 Y:0000 D:0000 DB:00 S:01FF P:envmxdizc V:261 H: 12 CYC:123456")
+	assert.Equal(t, "ASL", e.Op)
+	assert.EqualValues(t, 261, e.Fields["V"])
+	assert.EqualValues(t, 12, e.Fields["H"])
+	assert.EqualValues(t, 123456, e.Fields["CYC"])
+
+	for _, line := range []string{"", "2020/01/01 12:34:56 INFO: starting gose", "VBlank", "$00:8000 NOP"} {
+		_, ok := Parse(line)
+		assert.False(t, ok, line)
+	}
+}
+
+// newTestReader creates a reader from the given trace lines
+func newTestReader(lines ...string) *Reader {
+	return NewReader(strings.NewReader(strings.Join(lines, "\n")))
+}
+
+func TestCompare(t *testing.T) {
+	gose := []string{
+		"$00:8000 SEI                      A:0000 X:0000 Y:0000 D:0000 DB:00 S:01FF P:EnvMXdizc",
+		"$00:8001 CLC                      A:0000 X:0000 Y:0000 D:0000 DB:00 S:01FF P:EnvMXdIzc",
+		"VBlank",
+		"$00:8002 XCE                      A:0000 X:0000 Y:0000 D:0000 DB:00 S:01FF P:EnvMXdIzc",
+		"$00:8003 LDA #$12                 A:0000 X:0000 Y:0000 D:0000 DB:00 S:01FF P:envMXdIzc",
+		"$00:8005 TAX                      A:0012 X:0000 Y:0000 D:0000 DB:00 S:01FF P:envMXdIzc",
+	}
+	bsnes := []string{
+		"008000 sei                    A:0000 X:0000 Y:0000 S:01ff D:0000 DB:00 nvMXdizc V:  0 H: 186 F: 0",
+		"008001 clc                    A:0000 X:0000 Y:0000 S:01ff D:0000 DB:00 nvMXdIzc V:  0 H: 200 F: 0",
+		"008002 xce                    A:0000 X:0000 Y:0000 S:01ff D:0000 DB:00 nvMXdIzc V:  0 H: 212 F: 0",
+		"008003 lda #$12               A:0000 X:0000 Y:0000 S:01ff D:0000 DB:00 nvMXdIzc V:  0 H: 224 F: 0",
+		"008005 tax                    A:0012 X:0000 Y:0000 S:01ff D:0000 DB:00 nvMXdIzc V:  0 H: 240 F: 0",
+	}
+
+	// identical traces in different layouts
+	n, d, err := Compare(newTestReader(gose...), newTestReader(bsnes...), Options{})
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+	assert.Equal(t, 5, n)
+
+	// the first difference is reported with the instructions before it
+	diverging := append([]string{}, bsnes...)
+	diverging[4] = "008005 tax                    A:0013 X:0000 Y:0000 S:01ff D:0000 DB:00 nvMXdIzc V:  0 H: 240 F: 0"
+	n, d, err = Compare(newTestReader(gose...), newTestReader(diverging...), Options{Context: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "A", d.Field)
+	assert.Equal(t, 6, d.A.Line)
+	assert.Equal(t, 5, d.B.Line)
+	assert.Len(t, d.Before, 2)
+	assert.Equal(t, 4, d.Before[0][0].Line)
+	assert.Contains(t, d.String(), "instruction 4 differs on A: $12 != $13")
+
+	// fields can be ignored
+	_, d, _ = Compare(newTestReader(gose...), newTestReader(diverging...), Options{Fields: []string{"PC", "OP"}})
+	assert.Nil(t, d)
+
+	// a trace ends before the other one
+	_, d, _ = Compare(newTestReader(gose...), newTestReader(bsnes[:3]...), Options{})
+	assert.Nil(t, d.B)
+	assert.Equal(t, 3, d.Index)
+	assert.Contains(t, d.String(), "trace b is over after 3 instructions")
+
+	// the second trace starts earlier
+	_, d, _ = Compare(newTestReader(gose[3:]...), newTestReader(bsnes...), Options{})
+	assert.Equal(t, "PC", d.Field)
+	n, d, _ = Compare(newTestReader(gose[3:]...), newTestReader(bsnes...), Options{AlignPC: true})
+	assert.Nil(t, d)
+	assert.Equal(t, 3, n)
+}